@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+)
+
+// check requests every endpoint once, and prints whether the exporter can use
+// it. It returns the exit code, which is non-zero if any endpoint the enabled
+// collectors read is unusable.
+func check(args []string) int {
+	fs := flag.NewFlagSet(os.Args[0]+" check", flag.ExitOnError)
+	target := fs.String("target", "", "Name of the target to check instead of the slurmrestd under api")
+	cfg, _ := loadConfig(fs, args)
+	setupLogging(cfg)
+
+	// without a version, the endpoints would not be checked at all, while their
+	// answers tell best why detection failed
+	ctx, err := newCommandContext(cfg, *target, api.SupportedVersions[0])
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	v := ctx.Value(types.ApiVersionKey).(*api.Version)
+
+	fmt.Printf("Checking %s with slurm %s\n", ctx.Value(types.ApiURLKey).(string), v)
+	failed := 0
+	for _, c := range api.CheckEndpoints(ctx) {
+		printCheck(os.Stdout, c)
+		if !c.OK() && !c.Disabled {
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("%d endpoint(s) failed\n", failed)
+		return 1
+	}
+	fmt.Println("All endpoints are usable")
+	return 0
+}
+
+// printCheck writes what was found out about one endpoint
+func printCheck(w io.Writer, c api.EndpointCheck) {
+	result := "ok"
+	if !c.OK() {
+		result = "FAIL"
+	}
+	if c.Disabled {
+		fmt.Fprintf(w, "%s %s (%s), disabled\n", result, c.Name, c.Path)
+	} else {
+		fmt.Fprintf(w, "%s %s (%s)\n", result, c.Name, c.Path)
+	}
+	if !c.Reachable() {
+		fmt.Fprintf(w, "  reachable:   no, %v\n", c.Err)
+		return
+	}
+	fmt.Fprintf(w, "  reachable:   yes\n")
+	fmt.Fprintf(w, "  status:      %d\n", c.StatusCode)
+	if c.APIErrors != nil {
+		fmt.Fprintf(w, "  api errors:  %s\n", c.APIErrors.ToString())
+	}
+	switch {
+	case c.DataParser == "":
+		fmt.Fprintf(w, "  data parser: not reported, expected %s\n", c.Want)
+	case c.ParserMatches():
+		fmt.Fprintf(w, "  data parser: %s\n", c.DataParser)
+	default:
+		fmt.Fprintf(w, "  data parser: %s, expected %s\n", c.DataParser, c.Want)
+	}
+	switch {
+	case c.DecodeErr != nil:
+		fmt.Fprintf(w, "  decodes:     no, %v\n", c.DecodeErr)
+	case c.StatusCode != 200:
+		fmt.Fprintf(w, "  decodes:     not tried\n")
+	default:
+		fmt.Fprintf(w, "  decodes:     yes\n")
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
+)
+
+// dump saves the responses of the endpoints the enabled collectors read, in
+// the shape of the files under testdata/, to be attached to bug reports. It
+// returns the exit code.
+func dump(args []string) int {
+	fs := flag.NewFlagSet(os.Args[0]+" dump", flag.ExitOnError)
+	out := fs.String("out", "", "Directory to save the responses in")
+	anonymize := fs.Bool("anonymize", false, "Replace the names of users, accounts, hosts, jobs and the cluster, and user and group ids, with pseudonyms")
+	target := fs.String("target", "", "Name of the target to save the responses of instead of the slurmrestd under api")
+	cfg, _ := loadConfig(fs, args)
+	if *out == "" {
+		fmt.Println("--out is required")
+		return 2
+	}
+	setupLogging(cfg)
+
+	ctx, err := newCommandContext(cfg, *target, nil)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	var pseudonyms *api.Pseudonyms
+	if *anonymize {
+		pseudonyms = api.NewPseudonyms()
+	}
+	paths, err := api.Dump(ctx, *out, pseudonyms)
+	for _, path := range paths {
+		fmt.Printf("Saved %s\n", path)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
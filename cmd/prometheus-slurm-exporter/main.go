@@ -2,17 +2,22 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"github.com/akyoto/cache"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/config"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/slurm"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
 	"log"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"strconv"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -21,106 +26,453 @@ var err error
 var version = "1.0.11"
 
 func main() {
-	// set up logging
-	lvl := slog.LevelInfo
-	_, debug := os.LookupEnv("SLURM_EXPORTER_DEBUG")
-	if debug {
-		lvl = slog.LevelDebug
+	// the exporter serves metrics unless a command comes before the flags
+	command, args := "", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
-	l := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: lvl,
-	}))
-	slog.SetDefault(l)
-	slog.Debug("debug logging enabled")
+	switch command {
+	case "":
+		serve(args)
+	case "check":
+		os.Exit(check(args))
+	case "dump":
+		os.Exit(dump(args))
+	default:
+		fmt.Printf("Unknown command %q, expected one of: check, dump\n", command)
+		os.Exit(2)
+	}
+}
 
-	// if -v is passed, print the version and exit
-	if len(os.Args) > 1 && os.Args[1] == "-v" {
-		fmt.Println(version)
+// serve exports the metrics until the exporter is stopped
+func serve(args []string) {
+	cfg, checkOnly := loadConfig(flag.NewFlagSet(os.Args[0], flag.ExitOnError), args)
+	if checkOnly {
+		fmt.Println("Configuration is valid")
 		os.Exit(0)
 	}
+	setupLogging(cfg)
 
 	log.Printf("Starting Prometheus Slurm Exporter %s\n", version)
 
-	listenAddress, found := os.LookupEnv("SLURM_EXPORTER_LISTEN_ADDRESS")
-	if !found {
-		listenAddress = "0.0.0.0:8080"
+	// the collectors and their limits are the same for every target
+	ctx := context.Background()
+	enabledCollectors := enabledCollectors(cfg)
+	var enabledNames []string
+	for _, c := range enabledCollectors {
+		enabledNames = append(enabledNames, c.Name)
 	}
+	ctx = context.WithValue(ctx, types.ApiEnabledEndpointsKey, slurm.Endpoints(enabledCollectors))
+	log.Printf("Enabled collectors: %s\n", strings.Join(enabledNames, ", "))
 
-	apiUser, found := os.LookupEnv("SLURM_EXPORTER_API_USER")
-	if !found {
-		fmt.Println("You must set SLURM_EXPORTER_API_USER")
-		os.Exit(1)
+	// per-user, per-account, per-partition and per-node series can be limited
+	ctx = context.WithValue(ctx, types.CardinalityKey, cfg.Cardinality.Limits())
+
+	// user and account label values can be replaced with pseudonyms, which
+	// admins can look up again on a local socket
+	if cfg.Pseudonyms.KeyFile != "" {
+		pseudonyms, err := slurm.LoadLabelPseudonyms(cfg.Pseudonyms.KeyFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		ctx = context.WithValue(ctx, types.LabelPseudonymsKey, pseudonyms)
+		log.Println("Replacing user and account labels with pseudonyms")
+		if cfg.Pseudonyms.Socket != "" {
+			l, err := listenAdminSocket(cfg.Pseudonyms.Socket)
+			if err != nil {
+				fmt.Printf("Failed to listen for pseudonym lookups: %v\n", err)
+				os.Exit(1)
+			}
+			log.Printf("Serving pseudonym lookups on %s\n", cfg.Pseudonyms.Socket)
+			go func() {
+				log.Fatal(http.Serve(l, pseudonyms.Handler()))
+			}()
+		}
 	}
 
-	apiToken, found := os.LookupEnv("SLURM_EXPORTER_API_TOKEN")
-	if !found {
-		fmt.Println("You must set SLURM_EXPORTER_API_TOKEN")
-		os.Exit(1)
+	// the exporter's own metrics are shared by the targets, each scrape only
+	// gets the series about its own target
+	r := prometheus.NewRegistry()
+	api.RegisterMetrics(r)
+	// the enabled slurm collectors are built for every scrape, with the scrape's snapshot in ctx
+	collectors := func(ctx context.Context) []prometheus.Collector {
+		var cs []prometheus.Collector
+		for _, c := range enabledCollectors {
+			cs = append(cs, c.New(ctx))
+		}
+		return cs
 	}
 
-	apiURL, found := os.LookupEnv("SLURM_EXPORTER_API_URL")
-	if !found {
-		fmt.Println("You must set SLURM_EXPORTER_API_URL. Example: localhost:6820")
+	// the slurmrestd under api is the default target, the others are asked
+	// for with the target parameter
+	targets := make(map[string]http.Handler)
+	if cfg.API.URL != "" {
+		targets[""] = newTargetHandler(ctx, cfg, "", cfg.API, r, collectors)
+	}
+	for _, t := range cfg.Targets {
+		// already checked when the configuration was validated
+		a, _ := cfg.TargetAPI(t)
+		targets[t.Name] = newTargetHandler(ctx, cfg, t.Name, a, r, collectors)
+	}
+
+	log.Printf("Starting Server: %s\n", cfg.ListenAddress)
+	http.Handle("/metrics", api.TargetsHandler(targets))
+	if cfg.TLS.Enabled {
+		log.Fatal(http.ListenAndServeTLS(cfg.ListenAddress, cfg.TLS.CertFile, cfg.TLS.KeyFile, nil))
+	} else {
+		log.Fatal(http.ListenAndServe(cfg.ListenAddress, nil))
+	}
+}
+
+// versionRetryInterval is how often detecting the slurmrestd version of a
+// target is tried again while it is unknown
+const versionRetryInterval = 30 * time.Second
+
+// newTargetHandler sets up everything the exporter keeps per slurmrestd
+// target on top of ctx, and returns the handler that answers its scrapes.
+// Until the version of the target's slurmrestd is known, scrapes are answered
+// with 503 Service Unavailable while detecting it is retried in the background.
+func newTargetHandler(ctx context.Context, cfg *config.Config, name string, a config.API, r prometheus.Gatherer, collectors func(context.Context) []prometheus.Collector) http.Handler {
+	logPrefix := ""
+	if name != "" {
+		logPrefix = fmt.Sprintf("Target %s: ", name)
+	}
+	ctx = newAPIContext(ctx, cfg, name, a)
+
+	// jobs and nodes can be fetched incrementally, asking only for the records changed since the last request
+	var apiIncremental *api.Incremental
+	if a.Incremental {
+		apiIncremental = api.NewIncremental(api.IncrementalOptions{
+			Resync: a.IncrementalResync,
+		})
+	}
+
+	// responses are reused until their endpoint's ttl runs out, and the last good
+	// ones stand in for failed requests for up to the max staleness. With a
+	// snapshot directory they also survive restarts, each target keeping its
+	// own under the directory.
+	snapshotDir := cfg.Snapshot.Dir
+	if snapshotDir != "" && name != "" {
+		snapshotDir = filepath.Join(snapshotDir, name)
+	}
+	apiResponseCache := api.NewResponseCache(api.ResponseCacheOptions{
+		MaxStaleness: cfg.MaxStaleness,
+		TTLs:         a.TTLs,
+		Dir:          snapshotDir,
+		MaxAge:       cfg.Snapshot.MaxAge,
+	})
+	if err := apiResponseCache.Load(); err != nil {
+		fmt.Printf("%s%v\n", logPrefix, err)
 		os.Exit(1)
 	}
-	apiURL = api.CleanseBaseURL(apiURL)
+	ctx = context.WithValue(ctx, types.ApiIncrementalKey, apiIncremental)
+	ctx = context.WithValue(ctx, types.ApiResponseCacheKey, apiResponseCache)
 
-	tlsString, found := os.LookupEnv("SLURM_EXPORTER_ENABLE_TLS")
+	// the target's own metrics, next to those about it in r
+	tr := prometheus.NewRegistry()
+	var poller *api.Poller
+	if cfg.PollInterval > 0 {
+		poller = api.NewPoller(name, cfg.PollInterval)
+		ctx = context.WithValue(ctx, types.ApiPollerKey, poller)
+		tr.MustRegister(poller)
+	}
 
-	var tlsEnable bool
-	if !found {
-		tlsEnable = false // default to false, do not break existing conf files
-	} else {
-		tlsEnable, err = strconv.ParseBool(tlsString)
-		if err != nil {
-			fmt.Println("Failed to parse SLURM_EXPORTER_ENABLE_TLS.  Please set to 1, t, T, TRUE, true, True, 0, f, F, FALSE, false, or False.")
+	start := func(v *api.Version) http.Handler {
+		if err := apiResponseCache.SetVersion(v); err != nil {
+			slog.Warn("failed to record the slurmrestd version", "target", name, "error", err)
+		}
+		log.Printf("%sUsing slurm %s\n", logPrefix, v)
+		ctx := api.RegisterEndpoints(ctx, v)
+		if poller != nil {
+			log.Printf("%sPolling slurmrestd every %s\n", logPrefix, cfg.PollInterval)
+			go poller.Run(ctx)
+		}
+		return api.MetricsHandler(prometheus.Gatherers{api.TargetGatherer(r, name), tr}, ctx, collectors)
+	}
+
+	// Pick the data parser version, asking slurmrestd unless it was configured.
+	// If slurmrestd is down, the responses in the snapshot directory can still
+	// be served with the data parser they were recorded with.
+	v, err := selectVersion(ctx, a)
+	if err == nil {
+		return start(v)
+	}
+	if v := apiResponseCache.Version(); v != nil {
+		log.Printf("%sFailed to detect the slurmrestd version, using the one the snapshot directory was recorded with: %v\n", logPrefix, err)
+		return start(v)
+	}
+	log.Printf("%sFailed to detect the slurmrestd version, retrying every %s, set api.version to skip detection: %v\n", logPrefix, versionRetryInterval, err)
+
+	var started atomic.Pointer[http.Handler]
+	go func() {
+		t := time.NewTicker(versionRetryInterval)
+		defer t.Stop()
+		for range t.C {
+			v, err := selectVersion(ctx, a)
+			if err != nil {
+				slog.Warn("failed to detect the slurmrestd version", "target", name, "error", err)
+				continue
+			}
+			h := start(v)
+			started.Store(&h)
+			return
+		}
+	}()
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if h := started.Load(); h != nil {
+			(*h).ServeHTTP(w, req)
+			return
 		}
+		http.Error(w, "the slurmrestd version is not known yet", http.StatusServiceUnavailable)
+	})
+}
+
+// setupLogging makes slog the default logger, at debug level if configured
+func setupLogging(cfg *config.Config) {
+	lvl := slog.LevelInfo
+	if cfg.Debug {
+		lvl = slog.LevelDebug
 	}
-	var tlsCert, tlsKey string
-	if tlsEnable { // require tlsCert and tlsKey only if tlsEnable is true
-		tlsCert, found = os.LookupEnv("SLURM_EXPORTER_TLS_CERT_PATH")
-		if !found {
-			fmt.Println("You must set SLURM_EXPORTER_TLS_CERT_PATH to the path of your cert")
+	l := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: lvl,
+	}))
+	slog.SetDefault(l)
+	slog.Debug("debug logging enabled")
+}
+
+// newAPIContext returns a context on top of ctx with everything needed to
+// send requests to the slurmrestd of the named target, configured by a.
+// Problems with the credentials or TLS files are fatal.
+func newAPIContext(ctx context.Context, cfg *config.Config, name string, a config.API) context.Context {
+	apiURL := api.CleanseBaseURL(a.URL)
+	apiUser := a.User
+
+	// tokens are either minted from the slurm jwt key, read from a file, or provided as-is
+	var apiTokenSource api.TokenSource
+	if a.JWTKeyFile != "" {
+		apiTokenSource, err = api.NewJWTTokenSource(name, a.JWTKeyFile, apiUser, a.JWTLifespan)
+		if err != nil {
+			fmt.Printf("Failed to set up jwt signing: %v\n", err)
 			os.Exit(1)
 		}
-		tlsKey, found = os.LookupEnv("SLURM_EXPORTER_TLS_KEY_PATH")
-		if !found {
-			fmt.Println("You must set SLURM_EXPORTER_TLS_KEY_PATH to the path of your key")
+	} else if a.TokenFile != "" {
+		apiTokenSource, err = api.NewFileTokenSource(name, a.TokenFile)
+		if err != nil {
+			fmt.Printf("Failed to load SLURM_EXPORTER_API_TOKEN_FILE: %v\n", err)
 			os.Exit(1)
 		}
+	} else {
+		apiTokenSource = api.NewStaticTokenSource(name, a.Token)
 	}
 
-	// API Cache
-	apiCache := cache.New(60 * time.Second)
+	// TLS settings for the connection to slurmrestd
+	apiTLSOptions := api.TLSOptions{
+		CAFile:             a.CAFile,
+		CertFile:           a.CertFile,
+		KeyFile:            a.KeyFile,
+		ServerName:         a.ServerName,
+		InsecureSkipVerify: a.InsecureSkipVerify,
+	}
+	apiTLSConfig, err := api.NewTLSConfig(apiTLSOptions)
+	if err != nil {
+		fmt.Printf("Failed to configure TLS for the slurmrestd connection: %v\n", err)
+		os.Exit(1)
+	}
+	if apiTLSOptions.InsecureSkipVerify {
+		slog.Warn("certificate verification for slurmrestd is disabled")
+	}
+
+	// one client is shared by all requests so connections are reused
+	apiClient := api.NewHTTPClient(apiURL, api.ClientOptions{
+		TLSConfig:           apiTLSConfig,
+		DialTimeout:         a.DialTimeout,
+		TLSHandshakeTimeout: a.TLSTimeout,
+		ResponseTimeout:     a.ResponseTimeout,
+	})
+
+	// retries for transient failures, and circuit breakers for endpoints that keep failing
+	apiRetryOptions := api.RetryOptions{
+		Retries:  a.Retries,
+		Backoff:  a.RetryBackoff,
+		MaxDelay: a.RetryMaxBackoff,
+	}
+	apiBreakers := api.NewBreakers(api.BreakerOptions{
+		Threshold: a.BreakerThreshold,
+		Cooldown:  a.BreakerCooldown,
+		Target:    name,
+	})
 
 	// Set up the context to pass around
-	ctx := context.Background()
 	ctx = context.WithValue(ctx, types.ApiUserKey, apiUser)
-	ctx = context.WithValue(ctx, types.ApiTokenKey, apiToken)
+	ctx = context.WithValue(ctx, types.ApiTokenKey, apiTokenSource)
 	ctx = context.WithValue(ctx, types.ApiURLKey, apiURL)
-	ctx = context.WithValue(ctx, types.ApiCacheKey, apiCache)
+	ctx = context.WithValue(ctx, types.ApiTargetKey, name)
+	ctx = context.WithValue(ctx, types.ApiClientKey, apiClient)
+	ctx = context.WithValue(ctx, types.ApiRetryOptionsKey, apiRetryOptions)
+	ctx = context.WithValue(ctx, types.ApiBreakersKey, apiBreakers)
+	ctx = context.WithValue(ctx, types.ScrapeTimeoutOffsetKey, cfg.ScrapeTimeoutOffset)
+	return ctx
+}
 
-	// Register all the endpoints
-	ctx = api.RegisterEndpoints(ctx)
+// selectVersion returns the configured data parser version, or asks
+// slurmrestd which one it supports
+func selectVersion(ctx context.Context, a config.API) (*api.Version, error) {
+	if a.Version != "" {
+		// already checked when the configuration was validated
+		return api.LookupVersion(a.Version)
+	}
+	return api.DetectVersion(ctx)
+}
 
-	// Register all the collectors
-	r := prometheus.NewRegistry()
-	r.MustRegister(slurm.NewAccountsCollector(ctx))
-	r.MustRegister(slurm.NewCPUsCollector(ctx))
-	r.MustRegister(slurm.NewGPUsCollector(ctx))
-	r.MustRegister(slurm.NewNodesCollector(ctx))
-	r.MustRegister(slurm.NewNodeCollector(ctx))
-	r.MustRegister(slurm.NewPartitionsCollector(ctx))
-	r.MustRegister(slurm.NewFairShareCollector(ctx))
-	r.MustRegister(slurm.NewQueueCollector(ctx))
-	r.MustRegister(slurm.NewSchedulerCollector(ctx))
-	r.MustRegister(slurm.NewUsersCollector(ctx))
-
-	log.Printf("Starting Server: %s\n", listenAddress)
-	http.Handle("/metrics", api.MetricsHandler(r, ctx))
-	if tlsEnable {
-		log.Fatal(http.ListenAndServeTLS(listenAddress, tlsCert, tlsKey, nil))
-	} else {
-		log.Fatal(http.ListenAndServe(listenAddress, nil))
+// newCommandContext returns the context the commands besides serving metrics
+// send their requests to the named target with, or the default one when the
+// name is empty. Only the endpoints the enabled collectors read are enabled.
+// If the version can't be detected, the fallback version is used, or an error
+// is returned when there is none.
+func newCommandContext(cfg *config.Config, name string, fallback *api.Version) (context.Context, error) {
+	a, err := targetAPI(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+	ctx := newAPIContext(context.Background(), cfg, name, a)
+	v, err := selectVersion(ctx, a)
+	if err != nil {
+		if fallback == nil {
+			return nil, fmt.Errorf("failed to detect the slurmrestd version, set SLURM_EXPORTER_API_VERSION to skip detection: %v", err)
+		}
+		fmt.Printf("Failed to detect the slurmrestd version, assuming %s: %v\n", fallback, err)
+		v = fallback
+	}
+	ctx = api.RegisterEndpoints(ctx, v)
+	ctx = context.WithValue(ctx, types.ApiEnabledEndpointsKey, slurm.Endpoints(enabledCollectors(cfg)))
+	return ctx, nil
+}
+
+// targetAPI returns the api settings of the named target
+func targetAPI(cfg *config.Config, name string) (config.API, error) {
+	if name == "" {
+		if cfg.API.URL == "" {
+			return cfg.API, fmt.Errorf("api.url is not set, choose one of the targets with --target")
+		}
+		return cfg.API, nil
+	}
+	for _, t := range cfg.Targets {
+		if t.Name == name {
+			return cfg.TargetAPI(t)
+		}
+	}
+	return cfg.API, fmt.Errorf("unknown target %q", name)
+}
+
+// listenAdminSocket listens on a unix socket that only the exporter's user
+// and root can connect to. A socket left behind by an earlier run is replaced.
+func listenAdminSocket(path string) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	// the socket is created with the permissions the umask leaves, so it is
+	// narrowed first, or anyone could connect before the chmod below. The
+	// umask is process wide, this runs at startup before anything else
+	// creates files.
+	umask := syscall.Umask(0o077)
+	l, err := net.Listen("unix", path)
+	syscall.Umask(umask)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// enabledCollectors returns the collectors turned on in the configuration
+func enabledCollectors(cfg *config.Config) []slurm.Collector {
+	var enabled []slurm.Collector
+	for _, c := range slurm.Collectors {
+		if cfg.Collectors[c.Name] {
+			enabled = append(enabled, c)
+		}
+	}
+	return enabled
+}
+
+// loadConfig builds the configuration from the defaults, the config file, the
+// environment and the command line flags in args, in that order. Commands can
+// add their own flags to fs beforehand. Every problem found is printed before
+// exiting, instead of only the first. It also reports
+// whether the configuration should only be checked.
+func loadConfig(fs *flag.FlagSet, args []string) (*config.Config, bool) {
+	var (
+		configFile    string
+		configCheck   bool
+		showVersion   bool
+		listenAddress string
+		apiURL        string
+		apiUser       string
+		apiVersion    string
+		pollInterval  time.Duration
+	)
+	fs.StringVar(&configFile, "config.file", "", "Path to the YAML config file")
+	fs.BoolVar(&configCheck, "config.check", false, "Validate the configuration and exit")
+	fs.BoolVar(&showVersion, "v", false, "Print the version and exit")
+	fs.StringVar(&listenAddress, "web.listen-address", "", "Address to listen on (listen_address)")
+	fs.StringVar(&apiURL, "api.url", "", "URL of slurmrestd (api.url)")
+	fs.StringVar(&apiUser, "api.user", "", "User to authenticate to slurmrestd as (api.user)")
+	fs.StringVar(&apiVersion, "api.version", "", "Slurm release or data parser to use instead of detecting it (api.version)")
+	fs.DurationVar(&pollInterval, "poll.interval", 0, "How often to refresh the snapshot in polling mode (poll_interval)")
+	collectorFlags := make(map[string]*bool)
+	for _, c := range slurm.Collectors {
+		collectorFlags["collector."+c.Name] = fs.Bool("collector."+c.Name, false, fmt.Sprintf("Enable the %s collector", c.Name))
+		collectorFlags["no-collector."+c.Name] = fs.Bool("no-collector."+c.Name, false, fmt.Sprintf("Disable the %s collector", c.Name))
+	}
+	fs.Parse(args)
+
+	if showVersion {
+		fmt.Println(version)
+		os.Exit(0)
+	}
+
+	var errs config.Errors
+	cfg := config.Default()
+	if configFile != "" {
+		if err := cfg.LoadFile(configFile); err != nil {
+			if fileErrs, ok := err.(config.Errors); ok {
+				errs = append(errs, fileErrs...)
+			} else {
+				errs = append(errs, err)
+			}
+		}
+	}
+	errs = append(errs, cfg.ApplyEnv(os.LookupEnv)...)
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "web.listen-address":
+			cfg.ListenAddress = listenAddress
+		case "api.url":
+			cfg.API.URL = apiURL
+		case "api.user":
+			cfg.API.User = apiUser
+		case "api.version":
+			cfg.API.Version = apiVersion
+		case "poll.interval":
+			cfg.PollInterval = pollInterval
+		}
+		if name, found := strings.CutPrefix(f.Name, "collector."); found {
+			cfg.Collectors[name] = *collectorFlags[f.Name]
+		}
+		if name, found := strings.CutPrefix(f.Name, "no-collector."); found {
+			cfg.Collectors[name] = !*collectorFlags[f.Name]
+		}
+	})
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err.(config.Errors)...)
+	}
+	if len(errs) > 0 {
+		fmt.Println(errs)
+		os.Exit(1)
 	}
+	return cfg, configCheck
 }
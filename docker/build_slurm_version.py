@@ -13,6 +13,10 @@ if len(sys.argv) != 2:
 slurm_version = sys.argv[1]
 
 versions = {
+    "24.11": {
+        "api_version": "0.0.42",
+        "container_version": "24.11",
+    },
     "24.05": {
         "api_version": "0.0.41",
         "container_version": "24.05",
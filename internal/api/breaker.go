@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+// the values are exported as the circuit breaker state metric
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// BreakerOptions configures when an endpoint's circuit breaker opens and for how long
+type BreakerOptions struct {
+	// Threshold is the number of consecutive failed requests that opens the
+	// breaker. Zero disables the breakers.
+	Threshold int
+	Cooldown  time.Duration
+	// Target labels the breaker state metric with the slurmrestd the
+	// breakers are for
+	Target string
+}
+
+// Breakers holds a circuit breaker per slurmrestd endpoint. Once an endpoint
+// keeps failing, requests to it are skipped for a cool-down period instead of
+// adding load to a controller that is already struggling. After the cool-down
+// a single request is let through to test whether the endpoint recovered. A
+// test request that hasn't reported back within another cool-down is taken as
+// lost, and the next request is let through in its place.
+type Breakers struct {
+	opts BreakerOptions
+	now  func() time.Time
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+type circuitBreaker struct {
+	state    breakerState
+	failures int
+	openedAt time.Time
+	// testedAt is when the test request of a half-open breaker was let through
+	testedAt time.Time
+}
+
+// NewBreakers returns an empty set of circuit breakers with the given options
+func NewBreakers(o BreakerOptions) *Breakers {
+	return &Breakers{
+		opts:     o,
+		now:      time.Now,
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+func (b *Breakers) get(endpoint string) *circuitBreaker {
+	cb, found := b.breakers[endpoint]
+	if !found {
+		cb = &circuitBreaker{}
+		b.breakers[endpoint] = cb
+		breakerStateGauge.WithLabelValues(endpoint, b.opts.Target).Set(float64(breakerClosed))
+	}
+	return cb
+}
+
+func (b *Breakers) setState(endpoint string, cb *circuitBreaker, state breakerState) {
+	cb.state = state
+	breakerStateGauge.WithLabelValues(endpoint, b.opts.Target).Set(float64(state))
+}
+
+// Allow returns an error if requests to the endpoint are currently blocked
+func (b *Breakers) Allow(endpoint string) error {
+	if b.opts.Threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cb := b.get(endpoint)
+	switch cb.state {
+	case breakerOpen:
+		remaining := b.opts.Cooldown - b.now().Sub(cb.openedAt)
+		if remaining > 0 {
+			return fmt.Errorf("circuit breaker open for %s, retrying in %v", endpoint, remaining.Round(time.Second))
+		}
+		slog.Info("circuit breaker half-open, testing endpoint", "endpoint", endpoint)
+		cb.testedAt = b.now()
+		b.setState(endpoint, cb, breakerHalfOpen)
+		return nil
+	case breakerHalfOpen:
+		if b.now().Sub(cb.testedAt) < b.opts.Cooldown {
+			return fmt.Errorf("circuit breaker for %s is waiting on a test request", endpoint)
+		}
+		slog.Warn("circuit breaker test request never reported back, testing endpoint again", "endpoint", endpoint)
+		cb.testedAt = b.now()
+		return nil
+	}
+	return nil
+}
+
+// Success records a successful request, closing the endpoint's breaker
+func (b *Breakers) Success(endpoint string) {
+	if b.opts.Threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cb := b.get(endpoint)
+	if cb.state != breakerClosed {
+		slog.Info("circuit breaker closed", "endpoint", endpoint)
+	}
+	cb.failures = 0
+	b.setState(endpoint, cb, breakerClosed)
+}
+
+// Failure records a failed request, opening the endpoint's breaker once the
+// threshold is reached or when the test request of a half-open breaker fails
+func (b *Breakers) Failure(endpoint string) {
+	if b.opts.Threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cb := b.get(endpoint)
+	cb.failures++
+	if cb.state == breakerHalfOpen || cb.failures >= b.opts.Threshold {
+		if cb.state != breakerOpen {
+			slog.Warn("circuit breaker opened", "endpoint", endpoint, "failures", cb.failures, "cooldown", b.opts.Cooldown)
+		}
+		cb.openedAt = b.now()
+		b.setState(endpoint, cb, breakerOpen)
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakers(t *testing.T) {
+	b := NewBreakers(BreakerOptions{Threshold: 2, Cooldown: time.Minute})
+	now := time.Unix(1700000000, 0)
+	b.now = func() time.Time { return now }
+
+	b.Failure("jobs")
+	if err := b.Allow("jobs"); err != nil {
+		t.Fatalf("expected breaker to stay closed below the threshold: %v", err)
+	}
+	b.Failure("jobs")
+	if err := b.Allow("jobs"); err == nil {
+		t.Fatalf("expected breaker to open at the threshold")
+	}
+	if err := b.Allow("nodes"); err != nil {
+		t.Fatalf("expected other endpoints to be unaffected: %v", err)
+	}
+
+	// after the cool-down a single test request is let through
+	now = now.Add(time.Minute)
+	if err := b.Allow("jobs"); err != nil {
+		t.Fatalf("expected a test request after the cool-down: %v", err)
+	}
+	if err := b.Allow("jobs"); err == nil {
+		t.Fatalf("expected only one test request while half-open")
+	}
+
+	// a failed test request opens the breaker again
+	b.Failure("jobs")
+	if err := b.Allow("jobs"); err == nil {
+		t.Fatalf("expected breaker to reopen after a failed test request")
+	}
+
+	now = now.Add(time.Minute)
+	b.Allow("jobs")
+	b.Success("jobs")
+	if err := b.Allow("jobs"); err != nil {
+		t.Fatalf("expected breaker to close after a successful test request: %v", err)
+	}
+}
+
+func TestBreakersLostTestRequest(t *testing.T) {
+	b := NewBreakers(BreakerOptions{Threshold: 1, Cooldown: time.Minute})
+	now := time.Unix(1700000000, 0)
+	b.now = func() time.Time { return now }
+
+	b.Failure("jobs")
+	now = now.Add(time.Minute)
+	if err := b.Allow("jobs"); err != nil {
+		t.Fatalf("expected a test request after the cool-down: %v", err)
+	}
+
+	// the test request never calls Success or Failure, which must not block
+	// the endpoint for good
+	now = now.Add(30 * time.Second)
+	if err := b.Allow("jobs"); err == nil {
+		t.Fatalf("expected to wait on the test request within the cool-down")
+	}
+	now = now.Add(30 * time.Second)
+	if err := b.Allow("jobs"); err != nil {
+		t.Fatalf("expected another test request once the first one is lost: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+)
+
+// EndpointCheck is what CheckEndpoints found out about one endpoint
+type EndpointCheck struct {
+	Name string
+	Path string
+	// Err says why the endpoint could not be reached, if it could not
+	Err        error
+	StatusCode int
+	// APIErrors are the errors slurmrestd listed in the response, if any
+	APIErrors *APIErrorData
+	// DataParser is the data parser version slurmrestd says produced the
+	// response, e.g. "v0.0.41". Empty if the response does not say.
+	DataParser string
+	// Want is the data parser version the exporter decodes the response with
+	Want string
+	// DecodeErr says why the response could not be decoded, if it could not
+	DecodeErr error
+	// Disabled is set if none of the enabled collectors read the endpoint
+	Disabled bool
+}
+
+// Reachable reports whether slurmrestd answered the request at all
+func (c EndpointCheck) Reachable() bool {
+	return c.Err == nil
+}
+
+// ParserMatches reports whether the response was produced by the data parser
+// the exporter decodes it with. Responses that do not name their data parser
+// are given the benefit of the doubt.
+func (c EndpointCheck) ParserMatches() bool {
+	return c.DataParser == "" || c.DataParser == c.Want
+}
+
+// OK reports whether the endpoint is usable by the exporter
+func (c EndpointCheck) OK() bool {
+	return c.Reachable() && c.StatusCode == 200 && c.ParserMatches() && c.DecodeErr == nil
+}
+
+// checkedResponse holds the parts of every slurmrestd response the check looks at
+type checkedResponse struct {
+	Meta struct {
+		Plugin struct {
+			DataParser string `json:"data_parser"`
+		} `json:"plugin"`
+	} `json:"meta"`
+	APIErrorData
+}
+
+// CheckEndpoints requests every endpoint of the version once and reports
+// whether the exporter can use its response. Endpoints the enabled collectors
+// do not read are checked as well, and marked as disabled. Requests are not
+// retried and skip the circuit breakers, so every endpoint is tried exactly once.
+func CheckEndpoints(ctx context.Context) []EndpointCheck {
+	v := ctx.Value(types.ApiVersionKey).(*Version)
+	enabled := make(map[string]bool)
+	for _, e := range enabledEndpoints(ctx) {
+		enabled[e.name] = true
+	}
+	var checks []EndpointCheck
+	for _, e := range versionedEndpoints(v) {
+		c := checkEndpoint(ctx, v, e)
+		c.Disabled = !enabled[e.name]
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+func checkEndpoint(ctx context.Context, v *Version, e endpoint) EndpointCheck {
+	c := EndpointCheck{Name: e.name, Path: e.path, Want: v.Parser}
+	nr, err := newSlurmRestRequest(ctx, e.path)
+	if err != nil {
+		c.Err = fmt.Errorf("failed to generate new slurm rest request: %v", err)
+		return c
+	}
+	resp, err := nr.Send()
+	if err != nil {
+		c.Err = err
+		return c
+	}
+	c.StatusCode = resp.StatusCode
+	// errors like a rejected token may come without a body
+	if c.StatusCode != 200 && len(resp.Body) == 0 {
+		return c
+	}
+
+	var cr checkedResponse
+	if err := json.Unmarshal(resp.Body, &cr); err != nil {
+		c.DecodeErr = fmt.Errorf("response is not valid json: %v", err)
+		return c
+	}
+	if len(cr.Errors) > 0 {
+		c.APIErrors = &cr.APIErrorData
+	}
+	// slurmrestd names it like "data_parser/v0.0.41"
+	if _, p, found := strings.Cut(cr.Meta.Plugin.DataParser, "/"); found {
+		c.DataParser = p
+	}
+	if c.StatusCode == 200 {
+		c.DecodeErr = decodeResponse(v, e.name, resp.Body)
+	}
+	return c
+}
+
+// decodeResponse decodes the response of the endpoint the same way the
+// collectors do
+func decodeResponse(v *Version, name string, body []byte) error {
+	s := NewSnapshot(v, time.Now(), map[string][]byte{name: body})
+	var err error
+	switch name {
+	case "diag":
+		_, err = s.Diag()
+	case "jobs":
+		_, err = s.Jobs()
+	case "nodes":
+		_, err = s.Nodes()
+	case "partitions":
+		_, err = s.Partitions()
+	case "shares":
+		_, err = s.Shares()
+	default:
+		err = fmt.Errorf("unknown endpoint %q", name)
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+)
+
+func TestCheckEndpoints(t *testing.T) {
+	fixtures := map[string]string{
+		"/slurm/v0.0.42/jobs":       "V0042OpenapiJobInfoResp.json",
+		"/slurm/v0.0.42/nodes":      "V0042OpenapiNodesResp.json",
+		"/slurm/v0.0.42/partitions": "V0042OpenapiPartitionResp.json",
+		// answered by the wrong data parser
+		"/slurm/v0.0.42/diag": "V0040OpenapiDiagResp.json",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slurm/v0.0.42/shares" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"errors": [{"description": "Unable to query slurmdbd", "error_number": 7000, "error": "Unable to contact slurm controller", "source": "slurmdb_connection_get"}]}`))
+			return
+		}
+		w.Write(util.ReadTestDataBytes(fixtures[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	ctx := newTestContext(t, srv.URL, V0042)
+
+	checks := make(map[string]EndpointCheck)
+	for _, c := range CheckEndpoints(ctx) {
+		checks[c.Name] = c
+	}
+	if len(checks) != 5 {
+		t.Fatalf("expected 5 endpoints to be checked, got %d", len(checks))
+	}
+	for _, name := range []string{"jobs", "nodes", "partitions"} {
+		if c := checks[name]; !c.OK() || c.DataParser != "v0.0.42" {
+			t.Errorf("expected %s to be usable, got %+v", name, c)
+		}
+	}
+	if c := checks["diag"]; c.OK() || c.ParserMatches() || c.DataParser != "v0.0.40" {
+		t.Errorf("expected diag to fail on its data parser, got %+v", c)
+	}
+	if c := checks["shares"]; c.OK() || c.StatusCode != 500 || c.APIErrors == nil || c.APIErrors.Errors[0].ErrorNumber != 7000 {
+		t.Errorf("expected shares to fail with its api errors, got %+v", c)
+	}
+
+	srv.Close()
+	for _, c := range CheckEndpoints(ctx) {
+		if c.Reachable() || c.OK() {
+			t.Errorf("expected %s to be unreachable, got %+v", c.Name, c)
+		}
+	}
+}
+
+func TestCheckEndpointsDisabled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	ctx := newTestContext(t, srv.URL, V0042)
+	ctx = context.WithValue(ctx, types.ApiEnabledEndpointsKey, []string{"jobs", "nodes"})
+
+	checks := CheckEndpoints(ctx)
+	if len(checks) != 5 {
+		t.Fatalf("expected every endpoint to be checked, got %d", len(checks))
+	}
+	for _, c := range checks {
+		if c.StatusCode != 401 || c.OK() || c.DecodeErr != nil {
+			t.Errorf("expected %s to be unauthorized, got %+v", c.Name, c)
+		}
+		if wantDisabled := c.Name != "jobs" && c.Name != "nodes"; c.Disabled != wantDisabled {
+			t.Errorf("expected %s to be disabled %v, got %v", c.Name, wantDisabled, c.Disabled)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ClientOptions holds the connection settings for the slurmrestd http client
+type ClientOptions struct {
+	TLSConfig           *tls.Config
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	ResponseTimeout     time.Duration
+}
+
+// NewHTTPClient returns the http client shared by all requests to slurmrestd.
+// Connections are kept alive and reused between scrapes. Unix socket urls are
+// dialed directly instead of over tcp, and file urls are answered from the
+// responses recorded in the directory.
+func NewHTTPClient(apiURL string, o ClientOptions) *http.Client {
+	if IsFileURL(apiURL) {
+		return &http.Client{Transport: newReplayTransport(strings.TrimPrefix(apiURL, "file://"))}
+	}
+	dialer := &net.Dialer{
+		Timeout:   o.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	t := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       o.TLSConfig,
+		TLSHandshakeTimeout:   o.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: o.ResponseTimeout,
+		// every endpoint is requested at the same time during a scrape
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if IsUnixSocketURL(apiURL) {
+		socketPath := strings.TrimPrefix(apiURL, "unix://")
+		t.Proxy = nil
+		t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
+	}
+	return &http.Client{Transport: t}
+}
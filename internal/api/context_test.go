@@ -0,0 +1,23 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+)
+
+// newTestContext returns a context for sending requests to the test server at
+// url. The endpoints of v are registered unless v is nil.
+func newTestContext(t *testing.T, url string, v *Version) context.Context {
+	t.Helper()
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, types.ApiUserKey, "user")
+	ctx = context.WithValue(ctx, types.ApiTokenKey, NewStaticTokenSource("", "token"))
+	ctx = context.WithValue(ctx, types.ApiURLKey, CleanseBaseURL(url))
+	ctx = context.WithValue(ctx, types.ApiClientKey, NewHTTPClient(url, ClientOptions{}))
+	if v != nil {
+		ctx = RegisterEndpoints(ctx, v)
+	}
+	return ctx
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+)
+
+// fixtureSchemas maps endpoint names to the OpenAPI schema of their responses
+var fixtureSchemas = map[string]string{
+	"jobs":       "JobInfoResp",
+	"nodes":      "NodesResp",
+	"partitions": "PartitionResp",
+	"diag":       "DiagResp",
+	"shares":     "SharesResp",
+}
+
+// FixtureName returns the file name a response of the endpoint is saved
+// under, named like the files under testdata/, e.g. "V0041OpenapiJobInfoResp.json"
+func FixtureName(v *Version, name string) string {
+	return fixturePrefix(v) + fixtureSchemas[name] + ".json"
+}
+
+// fixturePrefix returns the start of the file names of the version's responses
+func fixturePrefix(v *Version) string {
+	return fmt.Sprintf("V%sOpenapi", strings.ReplaceAll(strings.TrimPrefix(v.Parser, "v"), ".", ""))
+}
+
+// Dump saves the response of every enabled endpoint in dir, and returns the
+// paths of the files it wrote. If pseudonyms are given, the names in the
+// responses are replaced first. Endpoints that fail are reported in an
+// EndpointErrors once the others are saved.
+func Dump(ctx context.Context, dir string, pseudonyms *Pseudonyms) ([]string, error) {
+	v := ctx.Value(types.ApiVersionKey).(*Version)
+	responses, fetchErr := fetchResponses(ctx, true)
+
+	// the responses contain user and account names
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create dump directory: %v", err)
+	}
+	var paths []string
+	for _, e := range enabledEndpoints(ctx) {
+		r, found := responses[e.name]
+		if !found {
+			continue
+		}
+		body := r.body
+		if pseudonyms != nil {
+			var err error
+			body, err = pseudonyms.Anonymize(body)
+			if err != nil {
+				return paths, fmt.Errorf("failed to anonymize %s response: %v", e.name, err)
+			}
+		}
+		path := filepath.Join(dir, FixtureName(v, e.name))
+		if err := os.WriteFile(path, body, 0o600); err != nil {
+			return paths, fmt.Errorf("failed to write %s response: %v", e.name, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, fetchErr
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+)
+
+func TestFixtureName(t *testing.T) {
+	if name := FixtureName(V0041, "jobs"); name != "V0041OpenapiJobInfoResp.json" {
+		t.Fatalf("unexpected fixture name %s", name)
+	}
+	if name := FixtureName(V0042, "partitions"); name != "V0042OpenapiPartitionResp.json" {
+		t.Fatalf("unexpected fixture name %s", name)
+	}
+}
+
+func TestDump(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/slurm/v0.0.42/")
+		if name == "shares" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(util.ReadTestDataBytes(FixtureName(V0042, name)))
+	}))
+	defer srv.Close()
+
+	ctx := newTestContext(t, srv.URL, V0042)
+
+	dir := filepath.Join(t.TempDir(), "dump")
+	paths, err := Dump(ctx, dir, nil)
+	if _, ok := err.(EndpointErrors); !ok || !strings.Contains(err.Error(), "shares") {
+		t.Fatalf("expected shares to fail, got %v", err)
+	}
+	if len(paths) != 4 {
+		t.Fatalf("expected the other 4 responses to be saved, got %v", paths)
+	}
+	// without pseudonyms the responses are saved as they came
+	for _, name := range []string{"jobs", "nodes", "partitions", "diag"} {
+		b, err := os.ReadFile(filepath.Join(dir, FixtureName(V0042, name)))
+		if err != nil {
+			t.Fatalf("failed to read saved %s response: %v", name, err)
+		}
+		if !bytes.Equal(b, util.ReadTestDataBytes(FixtureName(V0042, name))) {
+			t.Errorf("expected the %s response to be saved as it came", name)
+		}
+	}
+}
+
+func TestPseudonyms(t *testing.T) {
+	p := NewPseudonyms()
+	responses := make(map[string][]byte)
+	for _, name := range []string{"jobs", "nodes", "partitions", "diag", "shares"} {
+		b, err := p.Anonymize(util.ReadTestDataBytes(FixtureName(V0042, name)))
+		if err != nil {
+			t.Fatalf("failed to anonymize %s response: %v", name, err)
+		}
+		responses[name] = b
+	}
+	for name, b := range responses {
+		for _, real := range []string{"rdennis", "jamming", "vspauldi", "login1", "n0180", "uoregon", "/gpfs/home", "talapas", "110622", "239489", "Kill task failed"} {
+			if bytes.Contains(b, []byte(real)) {
+				t.Errorf("expected %q to be replaced in the %s response", real, name)
+			}
+		}
+	}
+
+	// the anonymized responses still decode, and the names still match up
+	jobs, err := ProcessJobsResponse(V0042, responses["jobs"])
+	if err != nil {
+		t.Fatalf("failed to process anonymized jobs response: %v", err)
+	}
+	nodes, err := ProcessNodesResponse(V0042, responses["nodes"])
+	if err != nil {
+		t.Fatalf("failed to process anonymized nodes response: %v", err)
+	}
+	if _, err := ProcessPartitionsResponse(V0042, responses["partitions"]); err != nil {
+		t.Fatalf("failed to process anonymized partitions response: %v", err)
+	}
+	if _, err := ProcessSharesResponse(V0042, responses["shares"]); err != nil {
+		t.Fatalf("failed to process anonymized shares response: %v", err)
+	}
+	if len(jobs.Jobs) == 0 || !strings.HasPrefix(jobs.Jobs[0].UserName, "user") || !strings.HasPrefix(jobs.Jobs[0].Account, "account") {
+		t.Fatalf("expected pseudonyms for the user and account of jobs, got %+v", jobs.Jobs[0])
+	}
+	if len(nodes.Nodes) == 0 || !strings.HasPrefix(nodes.Nodes[0].Hostname, "host") {
+		t.Fatalf("expected pseudonyms for the host names of nodes, got %+v", nodes.Nodes[0])
+	}
+
+	if h := p.hosts("n[0101-0120],gpu01"); h != p.hosts("n")+"[0101-0120],"+p.hosts("gpu")+"01" {
+		t.Fatalf("expected host lists to keep their shape, got %s", h)
+	}
+	if got := letters(0) + letters(25) + letters(26); got != "azaa" {
+		t.Fatalf("unexpected letters %s", got)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
 )
@@ -12,11 +13,42 @@ type endpoint struct {
 	path string
 }
 
-// this gives a compile warning but centralizes the endpoints
-var endpoints = versionedEndpoints
+// versionedEndpoints returns the slurmrestd endpoints for the data parser version
+func versionedEndpoints(v *Version) []endpoint {
+	return []endpoint{
+		{types.ApiJobsEndpointKey, "jobs", fmt.Sprintf("/slurm/%s/jobs", v.Parser)},
+		{types.ApiNodesEndpointKey, "nodes", fmt.Sprintf("/slurm/%s/nodes", v.Parser)},
+		{types.ApiPartitionsEndpointKey, "partitions", fmt.Sprintf("/slurm/%s/partitions", v.Parser)},
+		{types.ApiDiagEndpointKey, "diag", fmt.Sprintf("/slurm/%s/diag", v.Parser)},
+		{types.ApiSharesEndpointKey, "shares", fmt.Sprintf("/slurm/%s/shares", v.Parser)},
+	}
+}
+
+// enabledEndpoints returns the endpoints of the version that are requested,
+// which are all of them unless the context limits them to the names the
+// enabled collectors need
+func enabledEndpoints(ctx context.Context) []endpoint {
+	all := versionedEndpoints(ctx.Value(types.ApiVersionKey).(*Version))
+	names, found := ctx.Value(types.ApiEnabledEndpointsKey).([]string)
+	if !found {
+		return all
+	}
+	var enabled []endpoint
+	for _, e := range all {
+		for _, name := range names {
+			if e.name == name {
+				enabled = append(enabled, e)
+				break
+			}
+		}
+	}
+	return enabled
+}
 
-func RegisterEndpoints(ctx context.Context) context.Context {
-	for _, e := range endpoints {
+// RegisterEndpoints stores the version and its endpoint paths in the context
+func RegisterEndpoints(ctx context.Context, v *Version) context.Context {
+	ctx = context.WithValue(ctx, types.ApiVersionKey, v)
+	for _, e := range versionedEndpoints(v) {
 		ctx = context.WithValue(ctx, e.key, e.path)
 	}
 	return ctx
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+)
+
+// EndpointErrors holds why each failed endpoint failed, by endpoint name
+type EndpointErrors map[string]error
+
+func (e EndpointErrors) Error() string {
+	var names []string
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errmsgs []string
+	for _, name := range names {
+		errmsgs = append(errmsgs, e[name].Error())
+	}
+	return fmt.Sprintf("error(s) encountered calling slurm api: [%s]", strings.Join(errmsgs, ", "))
+}
+
+// response is what was fetched from an endpoint. Most responses are kept as
+// the body slurmrestd sent, and decoded when a collector first asks for them.
+// The jobs and nodes responses of a large cluster run into hundreds of
+// megabytes, so they are decoded as they are read instead, and only the data
+// the collectors use is kept, in jobs or nodes.
+type response struct {
+	body  []byte
+	jobs  *JobsData
+	nodes *NodesData
+}
+
+// streamedEndpoints maps the endpoints whose responses are decoded as they
+// are read to the function decoding them
+var streamedEndpoints = map[string]func(*Version, io.Reader) (response, error){
+	"jobs": func(v *Version, r io.Reader) (response, error) {
+		d, err := DecodeJobsResponse(v, r)
+		return response{jobs: d}, err
+	},
+	"nodes": func(v *Version, r io.Reader) (response, error) {
+		d, err := DecodeNodesResponse(v, r)
+		return response{nodes: d}, err
+	},
+}
+
+// fetchResponses requests every enabled endpoint of the version at once and
+// returns the responses by endpoint name. Every endpoint succeeds or fails on
+// its own: failed endpoints are left out, unless the response cache still has
+// a good response to stand in for them, and reported in an EndpointErrors.
+//
+// With raw, every response is kept as the body slurmrestd sent, even those
+// that are otherwise decoded as they are read.
+func fetchResponses(ctx context.Context, raw bool) (map[string]response, error) {
+	endpoints := enabledEndpoints(ctx)
+	incremental, _ := ctx.Value(types.ApiIncrementalKey).(*Incremental)
+	responseCache, _ := ctx.Value(types.ApiResponseCacheKey).(*ResponseCache)
+
+	var mu sync.Mutex
+	responses := make(map[string]response, len(endpoints))
+	errs := make(EndpointErrors)
+	var wg sync.WaitGroup
+	wg.Add(len(endpoints))
+
+	for _, e := range endpoints {
+		go func(e endpoint) {
+			defer wg.Done()
+			if r, fresh := responseCache.fresh(e.name); fresh && (!raw || r.body != nil) {
+				slog.Debug("reusing cached response", "endpoint", e.name)
+				mu.Lock()
+				responses[e.name] = r
+				mu.Unlock()
+				return
+			}
+			var r response
+			var err error
+			decode, streamed := streamedEndpoints[e.name]
+			switch {
+			case incremental != nil:
+				// the records are merged as json, so they are kept whole
+				r.body, err = incremental.fetch(ctx, e)
+			case streamed && !raw:
+				r, err = fetchStreamed(ctx, e, decode, responseCache)
+			default:
+				r.body, err = GetSlurmRestResponse(ctx, e.key)
+			}
+			found := err == nil
+			if err != nil {
+				endpointUp.WithLabelValues(e.name, targetName(ctx)).Set(0)
+				endpointErrors.WithLabelValues(e.name, targetName(ctx)).Inc()
+				err = fmt.Errorf("failed to get slurmrestd %s response: %v", e.path, err)
+				if stale, ok := responseCache.failure(e.name); ok && (!raw || stale.body != nil) {
+					slog.Warn("serving stale response", "target", targetName(ctx), "endpoint", e.name)
+					r, found = stale, true
+				}
+			} else {
+				endpointUp.WithLabelValues(e.name, targetName(ctx)).Set(1)
+				endpointLastSuccess.WithLabelValues(e.name, targetName(ctx)).SetToCurrentTime()
+				responseCache.success(e.name, r)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[e.name] = err
+			}
+			if found {
+				responses[e.name] = r
+			}
+		}(e)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return responses, errs
+	}
+	return responses, nil
+}
+
+// fetchStreamed requests the endpoint and decodes its response as it is read.
+// With a snapshot directory, the body is written there along the way.
+func fetchStreamed(ctx context.Context, e endpoint, decode func(*Version, io.Reader) (response, error), responseCache *ResponseCache) (response, error) {
+	v := ctx.Value(types.ApiVersionKey).(*Version)
+	f, err := responseCache.create(e.name)
+	if err != nil {
+		slog.Warn("failed to write response to snapshot directory", "endpoint", e.name, "error", err)
+	}
+	var r response
+	err = streamSlurmRestResponse(ctx, e.key, "", func(body io.Reader) error {
+		if f != nil {
+			f.reset()
+			body = io.TeeReader(body, f)
+		}
+		var err error
+		r, err = decode(v, body)
+		if err == nil && f != nil {
+			// whatever follows the decoded object belongs in the file too
+			_, err = io.Copy(io.Discard, body)
+		}
+		return err
+	})
+	if f != nil {
+		if err != nil {
+			f.abort()
+		} else if err := f.commit(responseCache.now()); err != nil {
+			slog.Warn("failed to write response to snapshot directory", "endpoint", e.name, "error", err)
+		}
+	}
+	return r, err
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFetchResponsesReusesFreshEntries(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	c := NewResponseCache(ResponseCacheOptions{TTLs: map[string]time.Duration{"shares": 5 * time.Minute}})
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	ctx := newTestContext(t, srv.URL, V0041)
+	ctx = context.WithValue(ctx, types.ApiResponseCacheKey, c)
+
+	for i := 0; i < 3; i++ {
+		responses, err := fetchResponses(ctx, false)
+		if err != nil {
+			t.Fatalf("failed to fetch responses: %v", err)
+		}
+		if len(responses) != 5 {
+			t.Fatalf("expected responses for 5 endpoints, got %d", len(responses))
+		}
+		now = now.Add(time.Minute)
+	}
+	if n := requests["/slurm/v0.0.41/shares"]; n != 1 {
+		t.Fatalf("expected shares to be requested once within its ttl, got %d", n)
+	}
+	if n := requests["/slurm/v0.0.41/jobs"]; n != 3 {
+		t.Fatalf("expected jobs to be requested on every fetch, got %d", n)
+	}
+}
+
+func TestFetchResponsesPartialFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slurm/v0.0.41/shares" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	ctx := newTestContext(t, srv.URL, V0041)
+
+	errorsBefore := testutil.ToFloat64(endpointErrors.WithLabelValues("shares", ""))
+	s := takeSnapshot(ctx)
+	if n := len(s.Endpoints()); n != 4 {
+		t.Fatalf("expected responses for the 4 healthy endpoints, got %d", n)
+	}
+	if s.Has("shares") {
+		t.Fatalf("expected no entry for the failed shares endpoint")
+	}
+	if _, err := s.Jobs(); err != nil {
+		t.Fatalf("expected jobs to decode despite the failed shares endpoint: %v", err)
+	}
+	if _, err := s.Shares(); err == nil || !strings.Contains(err.Error(), "/slurm/v0.0.41/shares") {
+		t.Fatalf("expected the shares error to say why the request failed, got %v", err)
+	}
+	if len(s.Errors) != 1 || s.Errors["shares"] == nil {
+		t.Fatalf("expected only shares to have failed, got %v", s.Errors)
+	}
+
+	if up := testutil.ToFloat64(endpointUp.WithLabelValues("shares", "")); up != 0 {
+		t.Fatalf("expected shares to be down, got %v", up)
+	}
+	if up := testutil.ToFloat64(endpointUp.WithLabelValues("jobs", "")); up != 1 {
+		t.Fatalf("expected jobs to be up, got %v", up)
+	}
+	if n := testutil.ToFloat64(endpointErrors.WithLabelValues("shares", "")) - errorsBefore; n != 1 {
+		t.Fatalf("expected one more shares error, got %v", n)
+	}
+}
+
+func TestFetchResponsesEnabledEndpoints(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	ctx := newTestContext(t, srv.URL, V0041)
+	ctx = context.WithValue(ctx, types.ApiEnabledEndpointsKey, []string{"jobs", "nodes"})
+
+	responses, err := fetchResponses(ctx, false)
+	if err != nil {
+		t.Fatalf("failed to fetch responses: %v", err)
+	}
+	if len(responses) != 2 || len(paths) != 2 {
+		t.Fatalf("expected only jobs and nodes to be requested, got %v", paths)
+	}
+	for _, p := range paths {
+		if p != "/slurm/v0.0.41/jobs" && p != "/slurm/v0.0.41/nodes" {
+			t.Fatalf("unexpected request to %s", p)
+		}
+	}
+}
+
+func TestFetchResponsesStreamsJobs(t *testing.T) {
+	jobs := util.ReadTestDataBytes("V0041OpenapiJobInfoResp.json")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(jobs)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	ctx := newTestContext(t, srv.URL, V0041)
+	ctx = context.WithValue(ctx, types.ApiEnabledEndpointsKey, []string{"jobs"})
+	ctx = context.WithValue(ctx, types.ApiResponseCacheKey, NewResponseCache(ResponseCacheOptions{Dir: dir}))
+
+	responses, err := fetchResponses(ctx, false)
+	if err != nil {
+		t.Fatalf("failed to fetch responses: %v", err)
+	}
+	r := responses["jobs"]
+	if r.body != nil || r.jobs == nil || len(r.jobs.Jobs) == 0 {
+		t.Fatalf("expected the jobs to be decoded as they were read, without keeping the body")
+	}
+
+	// the body is still written to the snapshot directory along the way
+	b, err := os.ReadFile(filepath.Join(dir, "jobs.json"))
+	if err != nil {
+		t.Fatalf("failed to read jobs response from snapshot directory: %v", err)
+	}
+	if !bytes.Equal(b, jobs) {
+		t.Fatalf("expected the jobs response to be written to the snapshot directory as it was sent")
+	}
+
+	// dumps need the body as it was sent
+	responses, err = fetchResponses(ctx, true)
+	if err != nil {
+		t.Fatalf("failed to fetch responses: %v", err)
+	}
+	if !bytes.Equal(responses["jobs"].body, jobs) {
+		t.Fatalf("expected the jobs response body with raw")
+	}
+}
@@ -2,30 +2,101 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func beforeCollect(ctx context.Context) {
-	err := PopulateCache(ctx)
+// scrapeContext returns a context for the slurmrestd requests of a single scrape.
+// Prometheus sends its scrape timeout in a header, and the requests are given
+// that long minus the configured offset, so the exporter can still answer with
+// whatever data it got before Prometheus gives up on the scrape.
+func scrapeContext(ctx context.Context, r *http.Request) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	// stop the requests if the client goes away
+	stop := context.AfterFunc(r.Context(), cancel)
+	cancelAll := func() {
+		stop()
+		cancel()
+	}
+
+	v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if v == "" {
+		return ctx, cancelAll
+	}
+	seconds, err := strconv.ParseFloat(v, 64)
 	if err != nil {
-		slog.Error("error populating request cache", "error", err)
+		slog.Debug("failed to parse scrape timeout header", "value", v, "error", err)
+		return ctx, cancelAll
+	}
+	timeout := time.Duration(seconds * float64(time.Second))
+	offset, _ := ctx.Value(types.ScrapeTimeoutOffsetKey).(time.Duration)
+	if offset < timeout {
+		timeout -= offset
+	}
+	ctx, cancelTimeout := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancelTimeout()
+		cancelAll()
 	}
 }
 
-func afterCollect(ctx context.Context) {
-	WipeCache(ctx)
+// snapshotFor returns the snapshot to answer a scrape from. In polling mode
+// that is the latest one, otherwise a new one is taken, shared with any other
+// scrapes that arrive while it is being taken.
+func snapshotFor(ctx context.Context, f *flight) *Snapshot {
+	if poller, _ := ctx.Value(types.ApiPollerKey).(*Poller); poller != nil {
+		s := poller.Latest()
+		if s == nil {
+			slog.Error("no snapshot of slurmrestd responses yet, the first poll has not finished")
+		}
+		return s
+	}
+	return f.do(ctx)
 }
 
-func MetricsHandler(r *prometheus.Registry, ctx context.Context) http.HandlerFunc {
-	h := promhttp.HandlerFor(r, promhttp.HandlerOpts{})
+// MetricsHandler answers scrapes with the exporter's own metrics from r, and
+// the slurm metrics of the collectors built by newCollectors. The collectors
+// are built again for every scrape, with the scrape's snapshot in their
+// context.
+func MetricsHandler(r prometheus.Gatherer, ctx context.Context, newCollectors func(context.Context) []prometheus.Collector) http.HandlerFunc {
+	var f flight
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		scrapeCtx, cancel := scrapeContext(ctx, req)
+		defer cancel()
+		s := snapshotFor(scrapeCtx, &f)
+
+		collectorCtx := context.WithValue(ctx, types.ApiSnapshotKey, s)
+		sr := prometheus.NewRegistry()
+		for _, c := range newCollectors(collectorCtx) {
+			sr.MustRegister(c)
+		}
+		promhttp.HandlerFor(prometheus.Gatherers{r, sr}, promhttp.HandlerOpts{}).ServeHTTP(w, req)
+	}
+}
 
-	return func(w http.ResponseWriter, r *http.Request) {
-		beforeCollect(ctx)
-		h.ServeHTTP(w, r)
-		afterCollect(ctx)
+// TargetsHandler passes each scrape on to the handler of the slurmrestd target
+// named by its target parameter. Scrapes without one go to the default
+// target, which has the empty name.
+func TargetsHandler(targets map[string]http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		name := req.URL.Query().Get("target")
+		h, found := targets[name]
+		if !found {
+			if name == "" {
+				http.Error(w, "no default target is configured, choose one with the target parameter", http.StatusBadRequest)
+				return
+			}
+			http.Error(w, fmt.Sprintf("unknown target %q", name), http.StatusNotFound)
+			return
+		}
+		h.ServeHTTP(w, req)
 	}
 }
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestScrapeContextDeadline(t *testing.T) {
+	ctx := context.WithValue(context.Background(), types.ScrapeTimeoutOffsetKey, 500*time.Millisecond)
+
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	r.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", "10")
+	scrapeCtx, cancel := scrapeContext(ctx, r)
+	defer cancel()
+	deadline, ok := scrapeCtx.Deadline()
+	if !ok {
+		t.Fatalf("expected the scrape context to have a deadline")
+	}
+	if remaining := time.Until(deadline); remaining > 9500*time.Millisecond || remaining < 9*time.Second {
+		t.Fatalf("expected the deadline to be about 9.5s away, got %v", remaining)
+	}
+
+	// without the header there is no deadline
+	r = httptest.NewRequest("GET", "/metrics", nil)
+	scrapeCtx, cancel = scrapeContext(ctx, r)
+	defer cancel()
+	if _, ok := scrapeCtx.Deadline(); ok {
+		t.Fatalf("expected no deadline without the scrape timeout header")
+	}
+}
+
+// snapshotCollector reports whether the jobs response in its snapshot decoded
+type snapshotCollector struct {
+	ctx  context.Context
+	desc *prometheus.Desc
+}
+
+func (c snapshotCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c snapshotCollector) Collect(ch chan<- prometheus.Metric) {
+	if _, err := c.ctx.Value(types.ApiSnapshotKey).(*Snapshot).Jobs(); err != nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1)
+}
+
+func TestMetricsHandlerConcurrentScrapes(t *testing.T) {
+	var jobsRequests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/jobs") {
+			jobsRequests.Add(1)
+		}
+		// slow enough that all scrapes overlap
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`{"jobs": []}`))
+	}))
+	defer srv.Close()
+
+	ctx := newTestContext(t, srv.URL, V0041)
+
+	desc := prometheus.NewDesc("test_jobs_decoded", "Whether the jobs response decoded", nil, nil)
+	h := MetricsHandler(prometheus.NewRegistry(), ctx, func(ctx context.Context) []prometheus.Collector {
+		return []prometheus.Collector{snapshotCollector{ctx, desc}}
+	})
+
+	const scrapes = 10
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	bodies := make([]string, scrapes)
+	for i := 0; i < scrapes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+			bodies[i] = w.Body.String()
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for i, b := range bodies {
+		if !strings.Contains(b, "test_jobs_decoded 1") {
+			t.Fatalf("scrape %d did not get the jobs response:\n%s", i, b)
+		}
+	}
+	if n := jobsRequests.Load(); n >= scrapes {
+		t.Fatalf("expected concurrent scrapes to share requests, got %d jobs requests for %d scrapes", n, scrapes)
+	}
+}
+
+func TestFlightOutlivesFirstScrape(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`{"jobs": []}`))
+	}))
+	defer srv.Close()
+
+	ctx := newTestContext(t, srv.URL, V0041)
+
+	var f flight
+	// the scrape that starts the snapshot goes away early, like a curl
+	// interrupted with ctrl-c
+	firstCtx, cancel := context.WithCancel(ctx)
+	first := make(chan *Snapshot)
+	go func() { first <- f.do(firstCtx) }()
+	time.Sleep(10 * time.Millisecond)
+
+	second := make(chan *Snapshot)
+	go func() { second <- f.do(ctx) }()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	if s := <-first; len(s.Errors) == 0 {
+		t.Fatalf("expected the abandoned scrape to report failed endpoints")
+	}
+	s := <-second
+	if !s.Has("jobs") {
+		t.Fatalf("expected the waiting scrape to get the jobs response: %v", s.Errors)
+	}
+}
+
+func TestFlightWaiterDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	ctx := newTestContext(t, srv.URL, V0041)
+
+	var f flight
+	go f.do(ctx)
+	time.Sleep(10 * time.Millisecond)
+
+	// a scrape joining a snapshot without a deadline still gives up at its own
+	shortCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	f.do(shortCtx)
+	if waited := time.Since(started); waited > 500*time.Millisecond {
+		t.Fatalf("expected the scrape to give up at its deadline, waited %v", waited)
+	}
+}
+
+func TestTargetsHandler(t *testing.T) {
+	answer := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		})
+	}
+	h := TargetsHandler(map[string]http.Handler{
+		"cluster2": answer("cluster2"),
+	})
+
+	scrape := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+	if w := scrape("/metrics?target=cluster2"); w.Body.String() != "cluster2" {
+		t.Fatalf("expected the cluster2 handler to answer, got %d %q", w.Code, w.Body.String())
+	}
+	if w := scrape("/metrics?target=cluster3"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown target to be not found, got %d", w.Code)
+	}
+	if w := scrape("/metrics"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a scrape without a target to be refused without a default target, got %d", w.Code)
+	}
+}
+
+func TestTargetGatherer(t *testing.T) {
+	up := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "up_test", Help: "test"}, []string{"endpoint", "target"})
+	up.WithLabelValues("jobs", "").Set(1)
+	up.WithLabelValues("jobs", "cluster2").Set(0)
+	other := prometheus.NewGauge(prometheus.GaugeOpts{Name: "other_test", Help: "test", ConstLabels: prometheus.Labels{"target": "cluster3"}})
+	r := prometheus.NewRegistry()
+	r.MustRegister(up, other)
+
+	mfs, err := TargetGatherer(r, "cluster2").Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 1 || len(mfs[0].Metric) != 1 || mfs[0].Metric[0].GetGauge().GetValue() != 0 {
+		t.Fatalf("expected only the cluster2 series, got %v", mfs)
+	}
+	mfs, _ = TargetGatherer(r, "").Gather()
+	if len(mfs) != 1 || len(mfs[0].Metric) != 1 || mfs[0].Metric[0].GetGauge().GetValue() != 1 {
+		t.Fatalf("expected only the default target's series, got %v", mfs)
+	}
+}
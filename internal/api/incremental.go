@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IncrementalOptions configures incremental fetching of the jobs and nodes endpoints
+type IncrementalOptions struct {
+	// Resync is how often the whole result set is fetched again. Records that
+	// slurmctld purged since the last full fetch, such as jobs older than
+	// MinJobAge, are only dropped then.
+	Resync time.Duration
+}
+
+// Incremental keeps the last jobs and nodes result sets, so later requests can
+// ask slurmrestd only for the records changed since, with the update_time
+// parameter, and merge those into the previous set.
+type Incremental struct {
+	opts IncrementalOptions
+	now  func() time.Time
+
+	mu   sync.Mutex
+	sets map[string]*resultSet
+}
+
+type resultSet struct {
+	// held for the whole request, so merges of one endpoint happen in order
+	mu         sync.Mutex
+	records    map[string]json.RawMessage
+	updateTime int64
+	lastFull   time.Time
+}
+
+// incrementalEndpoints maps the endpoints that accept update_time to the
+// function that finds the identity of one of their records
+var incrementalEndpoints = map[string]func(json.RawMessage) (string, error){
+	"jobs": func(r json.RawMessage) (string, error) {
+		var j struct {
+			JobID *int64 `json:"job_id"`
+		}
+		if err := json.Unmarshal(r, &j); err != nil || j.JobID == nil {
+			return "", fmt.Errorf("failed to find job_id in job record: %v", err)
+		}
+		return strconv.FormatInt(*j.JobID, 10), nil
+	},
+	"nodes": func(r json.RawMessage) (string, error) {
+		var n struct {
+			Name *string `json:"name"`
+		}
+		if err := json.Unmarshal(r, &n); err != nil || n.Name == nil {
+			return "", fmt.Errorf("failed to find name in node record: %v", err)
+		}
+		return *n.Name, nil
+	},
+}
+
+// NewIncremental returns an empty incremental store with the given options
+func NewIncremental(o IncrementalOptions) *Incremental {
+	return &Incremental{
+		opts: o,
+		now:  time.Now,
+		sets: make(map[string]*resultSet),
+	}
+}
+
+func (inc *Incremental) set(endpoint string) *resultSet {
+	inc.mu.Lock()
+	defer inc.mu.Unlock()
+	rs, found := inc.sets[endpoint]
+	if !found {
+		rs = &resultSet{}
+		inc.sets[endpoint] = rs
+	}
+	return rs
+}
+
+// fetch returns the full response of the endpoint. After the first full
+// fetch, only the changed records are requested and merged into the previous
+// result set, until the resync interval passes.
+func (inc *Incremental) fetch(ctx context.Context, e endpoint) ([]byte, error) {
+	idOf, found := incrementalEndpoints[e.name]
+	if !found {
+		return GetSlurmRestResponse(ctx, e.key)
+	}
+	rs := inc.set(e.name)
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	started := inc.now()
+	full := rs.records == nil || started.Sub(rs.lastFull) >= inc.opts.Resync
+	var query string
+	if !full {
+		// slurmctld skips records changed in the same second as update_time,
+		// so step back a second. records seen twice are merged anyway.
+		query = fmt.Sprintf("?update_time=%d", rs.updateTime-1)
+	}
+	body, err := getSlurmRestResponse(ctx, e.key, query)
+	if err != nil {
+		return nil, err
+	}
+
+	records, updateTime, err := splitRecords(body, e.name, idOf)
+	if err != nil {
+		// start over with a full fetch next time, the body is passed on as-is
+		// so the collectors report what is wrong with it
+		slog.Warn("failed to merge incremental response, the next request will fetch everything", "endpoint", e.name, "error", err)
+		rs.records = nil
+		return body, nil
+	}
+	if updateTime == 0 {
+		updateTime = started.Unix()
+	}
+	if updateTime > rs.updateTime || full {
+		rs.updateTime = updateTime
+	}
+
+	if full {
+		slog.Debug("fetched full result set", "endpoint", e.name, "records", len(records))
+		rs.records = records
+		rs.lastFull = started
+		return body, nil
+	}
+	maps.Copy(rs.records, records)
+	merged := joinRecords(e.name, rs.records)
+	slog.Debug("merged changed records", "endpoint", e.name, "changed", len(records), "records", len(rs.records))
+	if saved := len(merged) - len(body); saved > 0 {
+		incrementalSavedBytes.WithLabelValues(e.name, targetName(ctx)).Add(float64(saved))
+	}
+	return merged, nil
+}
+
+// splitRecords returns the records of the response keyed by their identity,
+// and the response's last_update time if it has one
+func splitRecords(body []byte, listKey string, idOf func(json.RawMessage) (string, error)) (map[string]json.RawMessage, int64, error) {
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, 0, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(resp[listKey], &list); err != nil {
+		return nil, 0, fmt.Errorf("failed to unmarshal %s: %v", listKey, err)
+	}
+	records := make(map[string]json.RawMessage, len(list))
+	for _, r := range list {
+		id, err := idOf(r)
+		if err != nil {
+			return nil, 0, err
+		}
+		records[id] = r
+	}
+
+	// last_update is a {set, infinite, number} struct since v0.0.40
+	var lastUpdate struct {
+		Set    bool  `json:"set"`
+		Number int64 `json:"number"`
+	}
+	if err := json.Unmarshal(resp["last_update"], &lastUpdate); err != nil || !lastUpdate.Set {
+		return records, 0, nil
+	}
+	return records, lastUpdate.Number, nil
+}
+
+// joinRecords builds a response body holding only the list of records, which
+// is all the collectors read from the jobs and nodes responses
+func joinRecords(listKey string, records map[string]json.RawMessage) []byte {
+	var b bytes.Buffer
+	ids := make([]string, 0, len(records))
+	for id := range records {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	fmt.Fprintf(&b, `{"%s":[`, listKey)
+	for i, id := range ids {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(records[id])
+	}
+	b.WriteString("]}")
+	return b.Bytes()
+}
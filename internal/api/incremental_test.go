@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+)
+
+func TestIncrementalFetch(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		if r.URL.Query().Get("update_time") == "" {
+			w.Write([]byte(`{"jobs": [{"job_id": 1, "job_state": ["RUNNING"]}, {"job_id": 2, "job_state": ["PENDING"]}], "last_update": {"set": true, "infinite": false, "number": 1700000000}}`))
+			return
+		}
+		w.Write([]byte(`{"jobs": [{"job_id": 2, "job_state": ["RUNNING"]}, {"job_id": 3, "job_state": ["PENDING"]}], "last_update": {"set": true, "infinite": false, "number": 1700000030}}`))
+	}))
+	defer srv.Close()
+
+	ctx := newTestContext(t, srv.URL, V0041)
+	e := endpoint{types.ApiJobsEndpointKey, "jobs", "/slurm/v0.0.41/jobs"}
+
+	inc := NewIncremental(IncrementalOptions{Resync: 10 * time.Minute})
+	now := time.Unix(1700000000, 0)
+	inc.now = func() time.Time { return now }
+
+	if _, err := inc.fetch(ctx, e); err != nil {
+		t.Fatalf("failed to fetch full result set: %v", err)
+	}
+	now = now.Add(30 * time.Second)
+	b, err := inc.fetch(ctx, e)
+	if err != nil {
+		t.Fatalf("failed to fetch changed records: %v", err)
+	}
+	want := `{"jobs":[{"job_id": 1, "job_state": ["RUNNING"]},{"job_id": 2, "job_state": ["RUNNING"]},{"job_id": 3, "job_state": ["PENDING"]}]}`
+	if string(b) != want {
+		t.Fatalf("unexpected merged body:\n got %s\nwant %s", b, want)
+	}
+
+	// once the resync interval passes everything is fetched again
+	now = now.Add(10 * time.Minute)
+	if _, err := inc.fetch(ctx, e); err != nil {
+		t.Fatalf("failed to resync: %v", err)
+	}
+	wantQueries := []string{"", "update_time=1699999999", ""}
+	if len(queries) != len(wantQueries) {
+		t.Fatalf("expected %d requests, got %d: %v", len(wantQueries), len(queries), queries)
+	}
+	for i := range wantQueries {
+		if queries[i] != wantQueries[i] {
+			t.Fatalf("request %d: expected query %q, got %q", i, wantQueries[i], queries[i])
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwtTokenSource signs its own short-lived tokens with the key slurmctld uses
+// for auth/jwt, so no long-lived token has to be generated and distributed.
+type jwtTokenSource struct {
+	key      []byte
+	user     string
+	lifespan time.Duration
+	target   string
+	now      func() time.Time
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewJWTTokenSource reads the HS256 key at keyFile (usually jwt_hs256.key) and
+// returns a TokenSource that mints tokens for user, valid for lifespan. The
+// token metrics are labelled with target.
+func NewJWTTokenSource(target string, keyFile string, user string, lifespan time.Duration) (TokenSource, error) {
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwt key: %v", err)
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("jwt key file %s is empty", keyFile)
+	}
+	if lifespan <= 0 {
+		return nil, fmt.Errorf("jwt lifespan must be positive")
+	}
+	return &jwtTokenSource{
+		key:      key,
+		user:     user,
+		lifespan: lifespan,
+		target:   target,
+		now:      time.Now,
+	}, nil
+}
+
+// Token returns the current token, minting a new one once the current token
+// has used up three quarters of its lifespan
+func (s *jwtTokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.token != "" && s.expires.Sub(now) > s.lifespan/4 {
+		return s.token, nil
+	}
+	token, err := s.sign(now)
+	if err != nil {
+		return "", err
+	}
+	slog.Debug("minted new slurm jwt", "user", s.user, "expires", now.Add(s.lifespan))
+	s.token = token
+	s.expires = now.Add(s.lifespan)
+	setTokenExpiry(s.target, token)
+	return s.token, nil
+}
+
+// sign builds a token in the same format as `scontrol token`. slurmctld reads
+// the user from the "sun" claim, "username" is included for sites that set
+// userclaimfield=username.
+func (s *jwtTokenSource) sign(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode jwt header: %v", err)
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat":      now.Unix(),
+		"exp":      now.Add(s.lifespan).Unix(),
+		"sun":      s.user,
+		"username": s.user,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode jwt claims: %v", err)
+	}
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil)), nil
+}
+
+// jwtExpiry returns the time in the exp claim of a jwt. The signature is not
+// checked, this is only used to report when the token stops working.
+func jwtExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("token is not a jwt")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode jwt claims: %v", err)
+	}
+	var claims struct {
+		Exp *float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("failed to unmarshal jwt claims: %v", err)
+	}
+	if claims.Exp == nil {
+		return time.Time{}, fmt.Errorf("jwt has no exp claim")
+	}
+	return time.Unix(int64(*claims.Exp), 0), nil
+}
+
+// setTokenExpiry updates the token expiry metric of the target for a newly
+// loaded token. The series is dropped when the expiry of the token is unknown.
+func setTokenExpiry(target string, token string) {
+	if token == "" {
+		tokenExpiry.DeleteLabelValues(target)
+		return
+	}
+	exp, err := jwtExpiry(token)
+	if err != nil {
+		slog.Debug("unable to read expiry from api token", "error", err)
+		tokenExpiry.DeleteLabelValues(target)
+		return
+	}
+	tokenExpiry.WithLabelValues(target).Set(float64(exp.Unix()))
+}
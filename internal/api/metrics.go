@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// These metrics describe the exporter itself rather than the slurm cluster.
+// They are labelled with the slurmrestd target they are about, which is empty
+// for the default one.
+
+var tokenLastLoad = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "slurm_exporter_token_last_load_timestamp_seconds",
+	Help: "Unix time the api token was last loaded from its file",
+}, []string{"target"})
+
+// tokenExpiry has no series for a target while the expiry of its token is
+// unknown, like for tokens without an exp claim
+var tokenExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "slurm_exporter_token_expiry_timestamp_seconds",
+	Help: "Unix time the current api token expires, taken from its exp claim",
+}, []string{"target"})
+
+var unauthorizedResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "slurm_exporter_api_unauthorized_responses_total",
+	Help: "Number of 401 Unauthorized responses from slurmrestd per endpoint",
+}, []string{"endpoint", "target"})
+
+var apiRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "slurm_exporter_api_retries_total",
+	Help: "Number of retried requests to slurmrestd per endpoint",
+}, []string{"endpoint", "target"})
+
+var breakerStateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "slurm_exporter_circuit_breaker_state",
+	Help: "State of the circuit breaker per endpoint (0 = closed, 1 = open, 2 = half-open)",
+}, []string{"endpoint", "target"})
+
+var incrementalSavedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "slurm_exporter_incremental_saved_bytes_total",
+	Help: "Bytes of slurmrestd responses not transferred thanks to incremental fetching per endpoint",
+}, []string{"endpoint", "target"})
+
+var endpointUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "slurm_exporter_endpoint_up",
+	Help: "Whether the last request to the slurmrestd endpoint succeeded",
+}, []string{"endpoint", "target"})
+
+var endpointErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "slurm_exporter_endpoint_errors_total",
+	Help: "Number of failed requests to the slurmrestd endpoint, after retries",
+}, []string{"endpoint", "target"})
+
+var endpointLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "slurm_exporter_endpoint_last_success_timestamp_seconds",
+	Help: "Unix time of the last successful request to the slurmrestd endpoint",
+}, []string{"endpoint", "target"})
+
+// RegisterMetrics registers the exporter's own metrics with the registry
+func RegisterMetrics(r *prometheus.Registry) {
+	r.MustRegister(tokenLastLoad)
+	r.MustRegister(tokenExpiry)
+	r.MustRegister(unauthorizedResponses)
+	r.MustRegister(apiRetries)
+	r.MustRegister(breakerStateGauge)
+	r.MustRegister(incrementalSavedBytes)
+	r.MustRegister(endpointUp)
+	r.MustRegister(endpointErrors)
+	r.MustRegister(endpointLastSuccess)
+}
+
+// targetName returns the name of the slurmrestd target the context sends its
+// requests to, which is empty for the default one
+func targetName(ctx context.Context) string {
+	target, _ := ctx.Value(types.ApiTargetKey).(string)
+	return target
+}
+
+// targetGatherer only passes on the series of the exporter's own metrics that
+// are about one target
+type targetGatherer struct {
+	g      prometheus.Gatherer
+	target string
+}
+
+// TargetGatherer returns a gatherer of the metrics from g that are about the
+// named target, so the scrapes of each target only get its own series
+func TargetGatherer(g prometheus.Gatherer, target string) prometheus.Gatherer {
+	return targetGatherer{g: g, target: target}
+}
+
+func (t targetGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := t.g.Gather()
+	var kept []*dto.MetricFamily
+	for _, mf := range mfs {
+		var metrics []*dto.Metric
+		for _, m := range mf.Metric {
+			if metricTarget(m) == t.target {
+				metrics = append(metrics, m)
+			}
+		}
+		if len(metrics) > 0 {
+			mf.Metric = metrics
+			kept = append(kept, mf)
+		}
+	}
+	return kept, err
+}
+
+// metricTarget returns the value of the target label of a metric
+func metricTarget(m *dto.Metric) string {
+	for _, l := range m.Label {
+		if l.GetName() == "target" {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
@@ -2,7 +2,6 @@ package api
 
 import (
 	"fmt"
-	"regexp"
 	"strconv"
 	"strings"
 
@@ -36,7 +35,7 @@ type DiagData struct {
 	BfBackfilledHetJobs    int32
 }
 
-func NewDiagData() *DiagData {
+func NewDiagData(apiVersion string) *DiagData {
 	return &DiagData{
 		ApiVersion: apiVersion,
 	}
@@ -189,8 +188,6 @@ type NodeData struct {
 	Name          string
 	Hostname      string
 	States        []types.NodeState
-	Tres          string
-	TresUsed      string
 	Partitions    []string
 	AllocMemory   int64
 	RealMemory    int64
@@ -202,7 +199,7 @@ type NodeData struct {
 	GPUAllocated  int32
 }
 
-func NewNodesData() *NodesData {
+func NewNodesData(apiVersion string) *NodesData {
 	return &NodesData{
 		ApiVersion: apiVersion,
 	}
@@ -229,18 +226,6 @@ func (n *NodeData) SetPartitions(partitions []string) error {
 	return nil
 }
 
-func (n *NodeData) SetTres(tres *string) {
-	if tres != nil {
-		n.Tres = *tres
-	}
-}
-
-func (n *NodeData) SetTresUsed(tresUsed *string) {
-	if tresUsed != nil {
-		n.TresUsed = *tresUsed
-	}
-}
-
 func (n *NodeData) SetAllocMemory(allocMemory *int64) error {
 	if allocMemory == nil {
 		n.AllocMemory = 0
@@ -334,6 +319,29 @@ func (n *NodeData) SetNodeGPUAllocated(tresString *string) error {
 	return nil
 }
 
+// nodeStatePrefixes maps the prefixes of lowercased node states to node states,
+// in the order they are matched
+var nodeStatePrefixes = []struct {
+	prefix string
+	state  types.NodeState
+}{
+	{"alloc", types.NodeStateAlloc},
+	{"comp", types.NodeStateComp},
+	{"down", types.NodeStateDown},
+	{"drain", types.NodeStateDrain},
+	{"fail", types.NodeStateFail},
+	{"err", types.NodeStateErr},
+	{"idle", types.NodeStateIdle},
+	{"maint", types.NodeStateMaint},
+	{"mix", types.NodeStateMix},
+	{"planned", types.NodeStatePlanned},
+	{"res", types.NodeStateResv},
+	{"not_responding", types.NodeStateNotResponding},
+	{"invalid", types.NodeStateInvalid},
+	{"invalid_reg", types.NodeStateInvalidReg},
+	{"dynamic_norm", types.NodeStateDynamicNorm},
+}
+
 func (n *NodeData) SetNodeStates(states []string) error {
 	var nodeStates []types.NodeState
 	if states == nil {
@@ -342,63 +350,18 @@ func (n *NodeData) SetNodeStates(states []string) error {
 	}
 
 	for _, s := range states {
-		state := string(s)
-		state = strings.ToLower(state)
-
-		alloc := regexp.MustCompile(`^alloc`)
-		comp := regexp.MustCompile(`^comp`)
-		down := regexp.MustCompile(`^down`)
-		drain := regexp.MustCompile(`^drain`)
-		fail := regexp.MustCompile(`^fail`)
-		err := regexp.MustCompile(`^err`)
-		idle := regexp.MustCompile(`^idle`)
-		maint := regexp.MustCompile(`^maint`)
-		mix := regexp.MustCompile(`^mix`)
-		planned := regexp.MustCompile(`^planned`)
-		resv := regexp.MustCompile(`^res`)
-		notresp := regexp.MustCompile(`^not_responding`)
-		invalid := regexp.MustCompile(`^invalid`)
-		invalidreg := regexp.MustCompile(`^invalid_reg`)
-		dynnorm := regexp.MustCompile(`^dynamic_norm`)
-
-		var stateUnit types.NodeState
-
-		switch {
-		case alloc.MatchString(state):
-			stateUnit = types.NodeStateAlloc
-		case comp.MatchString(state):
-			stateUnit = types.NodeStateComp
-		case down.MatchString(state):
-			stateUnit = types.NodeStateDown
-		case drain.MatchString(state):
-			stateUnit = types.NodeStateDrain
-		case fail.MatchString(state):
-			stateUnit = types.NodeStateFail
-		case err.MatchString(state):
-			stateUnit = types.NodeStateErr
-		case idle.MatchString(state):
-			stateUnit = types.NodeStateIdle
-		case maint.MatchString(state):
-			stateUnit = types.NodeStateMaint
-		case mix.MatchString(state):
-			stateUnit = types.NodeStateMix
-		case planned.MatchString(state):
-			stateUnit = types.NodeStatePlanned
-		case resv.MatchString(state):
-			stateUnit = types.NodeStateResv
-		case notresp.MatchString(state):
-			stateUnit = types.NodeStateNotResponding
-		case invalid.MatchString(state):
-			stateUnit = types.NodeStateInvalid
-		case invalidreg.MatchString(state):
-			stateUnit = types.NodeStateInvalidReg
-		case dynnorm.MatchString(state):
-			stateUnit = types.NodeStateDynamicNorm
-		default:
+		state := strings.ToLower(s)
+		found := false
+		for _, p := range nodeStatePrefixes {
+			if strings.HasPrefix(state, p.prefix) {
+				nodeStates = append(nodeStates, p.state)
+				found = true
+				break
+			}
+		}
+		if !found {
 			return fmt.Errorf("failed to match cpu state against known states: %v", state)
 		}
-
-		nodeStates = append(nodeStates, stateUnit)
 	}
 	n.States = nodeStates
 	return nil
@@ -412,54 +375,50 @@ func (n *NodeData) GetNodeStatesString(delim string) (string, error) {
 	return strings.Join(strStates, delim), nil
 }
 
-func (d *NodesData) FromResponse(r NodesResp) error {
+// AddNode converts a node record and appends it
+func (d *NodesData) AddNode(n NodeResp) error {
 	var err error
-	for _, n := range r.Nodes {
-		nd := NodeData{}
-		if err = nd.SetName(n.Name); err != nil {
-			return err
-		}
-		if err = nd.SetHostname(n.Hostname); err != nil {
-			return err
-		}
-		if err = nd.SetNodeStates(n.State); err != nil {
-			return err
-		}
-		if err = nd.SetPartitions(n.Partitions); err != nil {
-			return err
-		}
-		nd.SetTres(n.Tres)
-		nd.SetTresUsed(n.TresUsed)
-		if err = nd.SetTotalCPUs(n.Cpus); err != nil {
-			return err
-		}
-		if err = nd.SetAllocCPUs(n.AllocCpus); err != nil {
-			return err
-		}
-		if err = nd.SetIdleCPUs(n.AllocIdleCpus); err != nil {
-			return err
-		}
-		if err = nd.SetOtherCPUs(); err != nil {
-			return err
-		}
-
-		if err = nd.SetTotalMemory(n.RealMemory); err != nil {
-			return err
-		}
-		if err = nd.SetAllocMemory(n.AllocMemory); err != nil {
-			return err
-		}
+	nd := NodeData{}
+	if err = nd.SetName(n.Name); err != nil {
+		return err
+	}
+	if err = nd.SetHostname(n.Hostname); err != nil {
+		return err
+	}
+	if err = nd.SetNodeStates(n.State); err != nil {
+		return err
+	}
+	if err = nd.SetPartitions(n.Partitions); err != nil {
+		return err
+	}
+	if err = nd.SetTotalCPUs(n.Cpus); err != nil {
+		return err
+	}
+	if err = nd.SetAllocCPUs(n.AllocCpus); err != nil {
+		return err
+	}
+	if err = nd.SetIdleCPUs(n.AllocIdleCpus); err != nil {
+		return err
+	}
+	if err = nd.SetOtherCPUs(); err != nil {
+		return err
+	}
 
-		if err = nd.SetNodeGPUAllocated(n.TresUsed); err != nil {
-			return err
-		}
-		if err = nd.SetNodeGPUTotal(n.Tres); err != nil {
-			return err
-		}
+	if err = nd.SetTotalMemory(n.RealMemory); err != nil {
+		return err
+	}
+	if err = nd.SetAllocMemory(n.AllocMemory); err != nil {
+		return err
+	}
 
-		d.Nodes = append(d.Nodes, nd)
+	if err = nd.SetNodeGPUAllocated(n.TresUsed); err != nil {
+		return err
+	}
+	if err = nd.SetNodeGPUTotal(n.Tres); err != nil {
+		return err
 	}
 
+	d.Nodes = append(d.Nodes, nd)
 	return nil
 }
 
@@ -477,7 +436,7 @@ type JobData struct {
 	Dependency string
 }
 
-func NewJobsData() *JobsData {
+func NewJobsData(apiVersion string) *JobsData {
 	return &JobsData{
 		ApiVersion: apiVersion,
 	}
@@ -515,6 +474,26 @@ func (j *JobData) SetJobDependency(dependency *string) error {
 	return nil
 }
 
+// jobStatePrefixes maps the prefixes of lowercased job states to job states,
+// in the order they are matched
+var jobStatePrefixes = []struct {
+	prefix string
+	state  types.JobState
+}{
+	{"completed", types.JobStateCompleted},
+	{"pending", types.JobStatePending},
+	{"failed", types.JobStateFailed},
+	{"running", types.JobStateRunning},
+	{"suspended", types.JobStateSuspended},
+	{"out_of_memory", types.JobStateOutOfMemory},
+	{"timeout", types.JobStateTimeout},
+	{"cancelled", types.JobStateCancelled},
+	{"completing", types.JobStateCompleting},
+	{"configuring", types.JobStateConfiguring},
+	{"node_fail", types.JobStateNodeFail},
+	{"preempted", types.JobStatePreempted},
+}
+
 func (j *JobData) SetJobState(states []string) error {
 	if states == nil {
 		// job state is not found in the job response
@@ -523,51 +502,13 @@ func (j *JobData) SetJobState(states []string) error {
 	state := string((states)[0])
 	state = strings.ToLower(state)
 
-	completed := regexp.MustCompile(`^completed`)
-	pending := regexp.MustCompile(`^pending`)
-	failed := regexp.MustCompile(`^failed`)
-	running := regexp.MustCompile(`^running`)
-	suspended := regexp.MustCompile(`^suspended`)
-	out_of_memory := regexp.MustCompile(`^out_of_memory`)
-	timeout := regexp.MustCompile(`^timeout`)
-	cancelled := regexp.MustCompile(`^cancelled`)
-	completing := regexp.MustCompile(`^completing`)
-	configuring := regexp.MustCompile(`^configuring`)
-	node_fail := regexp.MustCompile(`^node_fail`)
-	preempted := regexp.MustCompile(`^preempted`)
-
-	var stateUnit types.JobState
-
-	switch {
-	case completed.MatchString(state):
-		stateUnit = types.JobStateCompleted
-	case pending.MatchString(state):
-		stateUnit = types.JobStatePending
-	case failed.MatchString(state):
-		stateUnit = types.JobStateFailed
-	case running.MatchString(state):
-		stateUnit = types.JobStateRunning
-	case suspended.MatchString(state):
-		stateUnit = types.JobStateSuspended
-	case out_of_memory.MatchString(state):
-		stateUnit = types.JobStateOutOfMemory
-	case timeout.MatchString(state):
-		stateUnit = types.JobStateTimeout
-	case cancelled.MatchString(state):
-		stateUnit = types.JobStateCancelled
-	case completing.MatchString(state):
-		stateUnit = types.JobStateCompleting
-	case configuring.MatchString(state):
-		stateUnit = types.JobStateConfiguring
-	case node_fail.MatchString(state):
-		stateUnit = types.JobStateNodeFail
-	case preempted.MatchString(state):
-		stateUnit = types.JobStatePreempted
-	default:
-		return fmt.Errorf("failed to match job state against known states: %v", state)
-	}
-	j.JobState = stateUnit
-	return nil
+	for _, p := range jobStatePrefixes {
+		if strings.HasPrefix(state, p.prefix) {
+			j.JobState = p.state
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to match job state against known states: %v", state)
 }
 
 func (j *JobData) SetJobCPUs(jobcpus *int32) error {
@@ -579,31 +520,29 @@ func (j *JobData) SetJobCPUs(jobcpus *int32) error {
 	return nil
 }
 
-func (d *JobsData) FromResponse(r JobsResp) error {
+// AddJob converts a job record and appends it
+func (d *JobsData) AddJob(j JobResp) error {
 	var err error
-	for _, j := range r.Jobs {
-		jd := JobData{}
-		if err = jd.SetJobAccount(j.Account); err != nil {
-			return err
-		}
-		if err = jd.SetJobUserName(j.UserName); err != nil {
-			return err
-		}
-		if err = jd.SetJobPartitionName(j.Partition); err != nil {
-			return err
-		}
-		if err = jd.SetJobState(j.JobState); err != nil {
-			return err
-		}
-		if err = jd.SetJobDependency(j.Dependency); err != nil {
-			return err
-		}
-		if err = jd.SetJobCPUs(j.JobResources.Cpus); err != nil {
-			return err
-		}
-		d.Jobs = append(d.Jobs, jd)
+	jd := JobData{}
+	if err = jd.SetJobAccount(j.Account); err != nil {
+		return err
 	}
-
+	if err = jd.SetJobUserName(j.UserName); err != nil {
+		return err
+	}
+	if err = jd.SetJobPartitionName(j.Partition); err != nil {
+		return err
+	}
+	if err = jd.SetJobState(j.JobState); err != nil {
+		return err
+	}
+	if err = jd.SetJobDependency(j.Dependency); err != nil {
+		return err
+	}
+	if err = jd.SetJobCPUs(j.JobResources.Cpus); err != nil {
+		return err
+	}
+	d.Jobs = append(d.Jobs, jd)
 	return nil
 }
 
@@ -619,7 +558,7 @@ type PartitionData struct {
 	Nodes     string
 }
 
-func NewPartitionsData() *PartitionsData {
+func NewPartitionsData(apiVersion string) *PartitionsData {
 	return &PartitionsData{
 		ApiVersion: apiVersion,
 	}
@@ -687,7 +626,7 @@ type ShareData struct {
 	EffectiveUsage float64
 }
 
-func NewSharesData() *SharesData {
+func NewSharesData(apiVersion string) *SharesData {
 	return &SharesData{
 		ApiVersion: apiVersion,
 	}
@@ -704,6 +643,7 @@ func (s *ShareData) SetName(name *string) error {
 func (s *ShareData) SetEffectiveUsage(effectiveUsage *float64) error {
 	if effectiveUsage == nil {
 		s.EffectiveUsage = float64(0)
+		return nil
 	}
 	s.EffectiveUsage = *effectiveUsage
 	return nil
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+)
+
+func TestSetJobState(t *testing.T) {
+	tests := []struct {
+		state string
+		want  types.JobState
+		err   bool
+	}{
+		{"RUNNING", types.JobStateRunning, false},
+		{"COMPLETED", types.JobStateCompleted, false},
+		{"COMPLETING", types.JobStateCompleting, false},
+		{"OUT_OF_MEMORY", types.JobStateOutOfMemory, false},
+		{"PREEMPTED", types.JobStatePreempted, false},
+		{"TELEPORTING", "", true},
+	}
+	for _, tt := range tests {
+		var j JobData
+		err := j.SetJobState([]string{tt.state, "REQUEUED"})
+		if (err != nil) != tt.err {
+			t.Fatalf("%s: expected error %v, got %v", tt.state, tt.err, err)
+		}
+		if j.JobState != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.state, tt.want, j.JobState)
+		}
+	}
+
+	var j JobData
+	if err := j.SetJobState(nil); err == nil {
+		t.Errorf("expected an error for a job without a state")
+	}
+}
+
+func TestSetNodeStates(t *testing.T) {
+	var n NodeData
+	if err := n.SetNodeStates([]string{"MIXED", "DRAIN", "RESERVED", "NOT_RESPONDING"}); err != nil {
+		t.Fatalf("failed to set node states: %v", err)
+	}
+	want := []types.NodeState{types.NodeStateMix, types.NodeStateDrain, types.NodeStateResv, types.NodeStateNotResponding}
+	if len(n.States) != len(want) {
+		t.Fatalf("expected %v, got %v", want, n.States)
+	}
+	for i := range want {
+		if n.States[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, n.States)
+		}
+	}
+
+	if err := n.SetNodeStates([]string{"IDLE", "TELEPORTING"}); err == nil {
+		t.Errorf("expected an error for an unknown node state")
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Poller refreshes all endpoints in the background on a fixed interval, so
+// scrapes can be answered from the latest snapshot without waiting on
+// slurmrestd, and several Prometheus servers don't multiply its load.
+type Poller struct {
+	interval time.Duration
+	now      func() time.Time
+	age      *prometheus.Desc
+
+	mu     sync.Mutex
+	latest *Snapshot
+}
+
+// NewPoller returns a poller of the target that refreshes every interval once
+// it is run
+func NewPoller(target string, interval time.Duration) *Poller {
+	return &Poller{
+		interval: interval,
+		now:      time.Now,
+		age:      prometheus.NewDesc("slurm_exporter_snapshot_age_seconds", "Age of the snapshot of slurmrestd responses the metrics are served from", nil, prometheus.Labels{"target": target}),
+	}
+}
+
+// Run polls slurmrestd until ctx is cancelled. The first poll happens right
+// away, and until it finishes the responses loaded from the snapshot
+// directory are served, if there are any.
+func (p *Poller) Run(ctx context.Context) {
+	p.seed(ctx)
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+	for {
+		p.poll(ctx)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// seed starts out with a snapshot of the responses in the response cache
+func (p *Poller) seed(ctx context.Context) {
+	responseCache, _ := ctx.Value(types.ApiResponseCacheKey).(*ResponseCache)
+	responses, oldest := responseCache.saved()
+	if len(responses) == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.latest == nil {
+		p.latest = newSnapshot(ctx.Value(types.ApiVersionKey).(*Version), oldest, responses)
+	}
+}
+
+// poll fetches every endpoint and keeps the result, leaving out the endpoints
+// that failed. Only if every endpoint failed is the previous snapshot kept.
+// A poll may not take longer than the interval, so they never overlap.
+func (p *Poller) poll(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, p.interval)
+	defer cancel()
+
+	started := p.now()
+	responses, err := fetchResponses(ctx, false)
+	if err != nil && len(responses) == 0 {
+		slog.Error("failed to refresh snapshot, keeping the previous one", "error", err)
+		return
+	}
+	s := newSnapshot(ctx.Value(types.ApiVersionKey).(*Version), started, responses)
+	if err != nil {
+		// with a max staleness the failed endpoints were already filled in
+		// with their last good response for as long as that is allowed
+		slog.Error("failed to refresh part of the snapshot", "error", err)
+		errors.As(err, &s.Errors)
+	}
+	slog.Debug("refreshed snapshot", "duration", p.now().Sub(started))
+
+	p.mu.Lock()
+	p.latest = s
+	p.mu.Unlock()
+}
+
+// Latest returns the most recent snapshot, or nil before the first poll has
+// succeeded for any endpoint
+func (p *Poller) Latest() *Snapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.latest
+}
+
+func (p *Poller) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.age
+}
+
+func (p *Poller) Collect(ch chan<- prometheus.Metric) {
+	s := p.Latest()
+	if s == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(p.age, prometheus.GaugeValue, p.now().Sub(s.Time).Seconds())
+}
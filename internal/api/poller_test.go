@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPollerKeepsPartialSnapshots(t *testing.T) {
+	failing := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing == "all" || failing == r.URL.Path {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	ctx := newTestContext(t, srv.URL, V0041)
+
+	p := NewPoller("hpc2", time.Minute)
+	now := time.Unix(1700000000, 0)
+	p.now = func() time.Time { return now }
+
+	if p.Latest() != nil {
+		t.Fatalf("expected no snapshot before the first poll")
+	}
+	p.poll(ctx)
+	first := p.Latest()
+	if first == nil {
+		t.Fatalf("expected a snapshot after a successful poll")
+	}
+	if n := len(first.Endpoints()); n != 5 {
+		t.Fatalf("expected responses for 5 endpoints, got %d", n)
+	}
+
+	// a failed endpoint is left out of the next snapshot
+	failing = "/slurm/v0.0.41/shares"
+	now = now.Add(time.Minute)
+	p.poll(ctx)
+	partial := p.Latest()
+	if partial == first {
+		t.Fatalf("expected a new snapshot when only some endpoints failed")
+	}
+	if partial.Has("shares") {
+		t.Fatalf("expected no shares response in the snapshot")
+	}
+	if !partial.Has("jobs") {
+		t.Fatalf("expected a jobs response in the snapshot")
+	}
+
+	// if every endpoint failed the previous snapshot is kept
+	failing = "all"
+	now = now.Add(time.Minute)
+	p.poll(ctx)
+	if p.Latest() != partial {
+		t.Fatalf("expected the previous snapshot to be kept when every endpoint failed")
+	}
+
+	now = now.Add(30 * time.Second)
+	want := `
+# HELP slurm_exporter_snapshot_age_seconds Age of the snapshot of slurmrestd responses the metrics are served from
+# TYPE slurm_exporter_snapshot_age_seconds gauge
+slurm_exporter_snapshot_age_seconds{target="hpc2"} 90
+`
+	if err := testutil.CollectAndCompare(p, strings.NewReader(want)); err != nil {
+		t.Fatalf("expected snapshot age of 90s for the target: %v", err)
+	}
+}
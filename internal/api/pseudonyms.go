@@ -0,0 +1,295 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Pseudonyms replaces the names of users, accounts, hosts, jobs and clusters,
+// and the ids of users and groups, in slurmrestd responses. A name always gets the same pseudonym, so responses of
+// different endpoints passed through the same Pseudonyms still fit together.
+type Pseudonyms struct {
+	// names maps each kind of name to the pseudonyms handed out so far
+	names map[string]map[string]string
+}
+
+// NewPseudonyms returns a Pseudonyms that hasn't handed out any pseudonyms yet
+func NewPseudonyms() *Pseudonyms {
+	return &Pseudonyms{names: make(map[string]map[string]string)}
+}
+
+// pseudonymFields says how the string fields holding names are replaced, by
+// their path in the response. Array indices are left out of the paths.
+var pseudonymFields = map[string]func(*Pseudonyms, string) string{
+	"meta.client.user":             (*Pseudonyms).user,
+	"meta.slurm.cluster":           (*Pseudonyms).cluster,
+	"statistics.rpcs_by_user.user": (*Pseudonyms).user,
+
+	"jobs.user_name":       (*Pseudonyms).user,
+	"jobs.mail_user":       (*Pseudonyms).user,
+	"jobs.group_name":      (*Pseudonyms).group,
+	"jobs.account":         (*Pseudonyms).account,
+	"jobs.cluster":         (*Pseudonyms).cluster,
+	"jobs.name":            (*Pseudonyms).job,
+	"jobs.allocating_node": (*Pseudonyms).hosts,
+	"jobs.batch_host":      (*Pseudonyms).hosts,
+	"jobs.nodes":           (*Pseudonyms).hosts,
+	"jobs.required_nodes":  (*Pseudonyms).hosts,
+	"jobs.excluded_nodes":  (*Pseudonyms).hosts,
+	"jobs.scheduled_nodes": (*Pseudonyms).hosts,
+	"jobs.failed_node":     (*Pseudonyms).hosts,
+	// the nodes of a job are listed differently by data parser v0.0.40 and later versions
+	"jobs.job_resources.nodes":                    (*Pseudonyms).hosts,
+	"jobs.job_resources.allocated_nodes.nodename": (*Pseudonyms).hosts,
+	"jobs.job_resources.nodes.list":               (*Pseudonyms).hosts,
+	"jobs.job_resources.nodes.allocation.name":    (*Pseudonyms).hosts,
+
+	"nodes.name":               (*Pseudonyms).hosts,
+	"nodes.hostname":           (*Pseudonyms).hosts,
+	"nodes.address":            (*Pseudonyms).hosts,
+	"nodes.owner":              (*Pseudonyms).user,
+	"nodes.reason_set_by_user": (*Pseudonyms).user,
+	"nodes.cluster_name":       (*Pseudonyms).cluster,
+
+	"partitions.cluster":          (*Pseudonyms).cluster,
+	"partitions.nodes.configured": (*Pseudonyms).hosts,
+	"partitions.accounts.allowed": (*Pseudonyms).accounts,
+	"partitions.accounts.deny":    (*Pseudonyms).accounts,
+	"partitions.groups.allowed":   (*Pseudonyms).groups,
+
+	"shares.shares.parent":  (*Pseudonyms).account,
+	"shares.shares.cluster": (*Pseudonyms).cluster,
+}
+
+// pseudonymIDs are the numeric user and group ids, which are replaced by
+// made up ones so they can't be looked up in the site's directory
+var pseudonymIDs = map[string]string{
+	"statistics.rpcs_by_user.user_id": "uid",
+	"jobs.user_id":                    "uid",
+	"jobs.group_id":                   "gid",
+}
+
+// clearedFields are free text fields that often hold paths or notes with
+// names in them. The exporter doesn't read them, so they are emptied.
+var clearedFields = map[string]bool{
+	"meta.client.source":             true,
+	"jobs.command":                   true,
+	"jobs.current_working_directory": true,
+	"jobs.standard_input":            true,
+	"jobs.standard_output":           true,
+	"jobs.standard_error":            true,
+	"jobs.comment":                   true,
+	"jobs.admin_comment":             true,
+	"jobs.system_comment":            true,
+	"jobs.extra":                     true,
+	"nodes.comment":                  true,
+	"nodes.extra":                    true,
+	"nodes.reason":                   true,
+}
+
+// Anonymize returns the response with the names in it replaced by pseudonyms,
+// indented like the files under testdata/
+func (p *Pseudonyms) Anonymize(body []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	v = p.replace(v, "")
+
+	var b bytes.Buffer
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(false)
+	e.SetIndent("", "  ")
+	if err := e.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode response: %v", err)
+	}
+	return b.Bytes(), nil
+}
+
+// replace returns v with the names in it replaced. Object keys are visited in
+// order, so the same response always gets the same pseudonyms.
+func (p *Pseudonyms) replace(v any, path string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		var keys []string
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v[k] = p.replace(v[k], join(path, k))
+		}
+		// the name of a shares entry is a user or an account, depending on its type
+		if path == "shares.shares" {
+			if name, ok := v["name"].(string); ok {
+				if isUserShare(v["type"]) {
+					v["name"] = p.user(name)
+				} else {
+					v["name"] = p.account(name)
+				}
+			}
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = p.replace(v[i], path)
+		}
+		return v
+	case string:
+		if clearedFields[path] {
+			return ""
+		}
+		if f, found := pseudonymFields[path]; found {
+			return f(p, v)
+		}
+		return v
+	case json.Number:
+		if kind, found := pseudonymIDs[path]; found {
+			return p.id(kind, v)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// isUserShare reports whether a shares entry of the given type is a user,
+// which is a string in some data parser versions and a list in others
+func isUserShare(t any) bool {
+	switch t := t.(type) {
+	case string:
+		return t == "USER"
+	case []any:
+		for _, s := range t {
+			if s == "USER" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pseudonym returns the pseudonym of a name of the given kind, like "user3".
+// Empty names and root are left alone.
+func (p *Pseudonyms) pseudonym(kind, name string) string {
+	if name == "" || name == "root" {
+		return name
+	}
+	names, found := p.names[kind]
+	if !found {
+		names = make(map[string]string)
+		p.names[kind] = names
+	}
+	pseudonym, found := names[name]
+	if !found {
+		pseudonym = fmt.Sprintf("%s%d", kind, len(names)+1)
+		names[name] = pseudonym
+	}
+	return pseudonym
+}
+
+func (p *Pseudonyms) user(name string) string    { return p.pseudonym("user", name) }
+func (p *Pseudonyms) group(name string) string   { return p.pseudonym("group", name) }
+func (p *Pseudonyms) account(name string) string { return p.pseudonym("account", name) }
+func (p *Pseudonyms) job(name string) string     { return p.pseudonym("job", name) }
+func (p *Pseudonyms) cluster(name string) string { return p.pseudonym("cluster", name) }
+
+// id returns the made up id of a user or group id of the given kind, counting
+// up from 10000 like the ids of regular users. Root's id 0 is left alone.
+func (p *Pseudonyms) id(kind string, id json.Number) json.Number {
+	if id == "0" {
+		return id
+	}
+	ids, found := p.names[kind]
+	if !found {
+		ids = make(map[string]string)
+		p.names[kind] = ids
+	}
+	pseudonym, found := ids[id.String()]
+	if !found {
+		pseudonym = strconv.Itoa(10000 + len(ids))
+		ids[id.String()] = pseudonym
+	}
+	return json.Number(pseudonym)
+}
+
+// accounts replaces the names in a comma separated list of accounts
+func (p *Pseudonyms) accounts(list string) string {
+	return p.list(list, p.account)
+}
+
+// groups replaces the names in a comma separated list of groups
+func (p *Pseudonyms) groups(list string) string {
+	return p.list(list, p.group)
+}
+
+func (p *Pseudonyms) list(list string, f func(string) string) string {
+	names := strings.Split(list, ",")
+	for i, name := range names {
+		names[i] = f(name)
+	}
+	return strings.Join(names, ",")
+}
+
+// hosts replaces the host names in a host name, a list of them or a host list
+// expression like "n[0101-0120],gpu01". Every run of letters is replaced and
+// the numbers are kept, so "n0101" and "n[0101-0120]" still match.
+func (p *Pseudonyms) hosts(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if !isLetter(s[i]) {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && isLetter(s[j]) {
+			j++
+		}
+		b.WriteString(p.hostPart(s[i:j]))
+		i = j
+	}
+	return b.String()
+}
+
+// hostPart returns the pseudonym of a run of letters in a host name. It is
+// made of letters too, like "hostb", so the numbers around it stay apart.
+func (p *Pseudonyms) hostPart(part string) string {
+	names, found := p.names["host"]
+	if !found {
+		names = make(map[string]string)
+		p.names["host"] = names
+	}
+	pseudonym, found := names[part]
+	if !found {
+		pseudonym = "host" + letters(len(names))
+		names[part] = pseudonym
+	}
+	return pseudonym
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// letters returns the n-th name in a, b, ..., z, aa, ab, ...
+func letters(n int) string {
+	s := ""
+	for n++; n > 0; n = (n - 1) / 26 {
+		s = string(rune('a'+(n-1)%26)) + s
+	}
+	return s
+}
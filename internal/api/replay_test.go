@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+)
+
+// writeFixtures copies the recorded responses of the endpoints into dir
+func writeFixtures(t *testing.T, dir string, v *Version, names ...string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, FixtureName(v, name)), util.ReadTestDataBytes(FixtureName(v, name)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func replayContext(dir string) context.Context {
+	url := CleanseBaseURL("file://" + dir)
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, types.ApiURLKey, url)
+	ctx = context.WithValue(ctx, types.ApiClientKey, NewHTTPClient(url, ClientOptions{}))
+	return ctx
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	writeFixtures(t, dir, V0042, "jobs", "nodes", "partitions", "diag", "shares")
+
+	ctx := replayContext(dir)
+	v, err := DetectVersion(ctx)
+	if err != nil {
+		t.Fatalf("failed to detect version of recorded responses: %v", err)
+	}
+	if v != V0042 {
+		t.Fatalf("detected %s, want %s", v, V0042)
+	}
+	ctx = RegisterEndpoints(ctx, v)
+
+	s := takeSnapshot(ctx)
+	if s.Errors != nil {
+		t.Fatalf("failed to take snapshot of recorded responses: %v", s.Errors)
+	}
+	jobs, err := s.Jobs()
+	if err != nil || len(jobs.Jobs) == 0 {
+		t.Fatalf("expected the recorded jobs, got %v", err)
+	}
+}
+
+func TestReplaySequence(t *testing.T) {
+	dir := t.TempDir()
+	// the nodes endpoint fails in the second snapshot
+	writeFixtures(t, filepath.Join(dir, "2025-01-01T10:00:00Z"), V0042, "jobs", "nodes")
+	writeFixtures(t, filepath.Join(dir, "2025-01-01T10:01:00Z"), V0042, "jobs")
+	writeFixtures(t, filepath.Join(dir, "2025-01-01T10:02:00Z"), V0042, "jobs", "nodes")
+
+	ctx := RegisterEndpoints(replayContext(dir), V0042)
+	ctx = context.WithValue(ctx, types.ApiEnabledEndpointsKey, []string{"jobs", "nodes"})
+
+	var failed []bool
+	for i := 0; i < 4; i++ {
+		_, err := fetchResponses(ctx, false)
+		failed = append(failed, err != nil)
+		if err != nil && !strings.Contains(err.Error(), "nodes") {
+			t.Fatalf("expected only nodes to fail, got %v", err)
+		}
+	}
+	// the last snapshot keeps being served once the sequence ends
+	if failed[0] || !failed[1] || failed[2] || failed[3] {
+		t.Fatalf("expected only the second fetch to fail, got %v", failed)
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ResponseCacheOptions configures how long responses are kept
+type ResponseCacheOptions struct {
+	// MaxStaleness is how long the last good response of an endpoint keeps
+	// being served after requests to it start failing. Zero disables it.
+	MaxStaleness time.Duration
+	// TTLs maps endpoint names to how long their responses are reused before
+	// they are requested again. Endpoints without a TTL are requested every time.
+	TTLs map[string]time.Duration
+	// Dir is where the responses are kept on disk to survive restarts, if set
+	Dir string
+	// MaxAge is how old responses on disk may be to be loaded at startup.
+	// Zero loads them regardless of their age.
+	MaxAge time.Duration
+}
+
+// ResponseCache keeps the last good response of every endpoint. Each entry
+// is reused until its endpoint's TTL runs out, and can stand in for failed
+// requests for up to the max staleness, for example while slurmrestd restarts.
+// Responses loaded from the snapshot directory stand in for failed requests
+// for up to the max age instead, until the endpoint answers again.
+type ResponseCache struct {
+	opts ResponseCacheOptions
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+	// version is the data parser the responses were recorded with, if known
+	version *Version
+}
+
+type cachedResponse struct {
+	response
+	time    time.Time
+	expires time.Time
+	// loaded is set for responses loaded from the snapshot directory
+	loaded bool
+}
+
+// NewResponseCache returns an empty response cache with the given options
+func NewResponseCache(o ResponseCacheOptions) *ResponseCache {
+	return &ResponseCache{
+		opts:    o,
+		now:     time.Now,
+		entries: make(map[string]cachedResponse),
+	}
+}
+
+// success records a good response of the endpoint. Responses decoded as they
+// were read have no body left to write to the snapshot directory, they are
+// written there as they are read instead (see create).
+func (c *ResponseCache) success(name string, r response) {
+	if c == nil {
+		return
+	}
+	now := c.now()
+	if c.opts.Dir != "" && r.body != nil {
+		if err := c.persist(name, r.body, now); err != nil {
+			slog.Warn("failed to write response to snapshot directory", "endpoint", name, "error", err)
+		}
+	}
+	ttl := c.opts.TTLs[name]
+	if ttl <= 0 && c.opts.MaxStaleness <= 0 && c.opts.Dir == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[name] = cachedResponse{response: r, time: now, expires: now.Add(ttl)}
+}
+
+// fresh returns the cached response of the endpoint if its TTL has not run out
+func (c *ResponseCache) fresh(name string) (response, bool) {
+	if c == nil {
+		return response{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, found := c.entries[name]
+	if !found || !c.now().Before(r.expires) {
+		return response{}, false
+	}
+	return r.response, true
+}
+
+// failure returns the last good response of a failed endpoint, if it is recent
+// enough to stand in for it
+func (c *ResponseCache) failure(name string) (response, bool) {
+	if c == nil {
+		return response{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, found := c.entries[name]
+	if !found {
+		return response{}, false
+	}
+	maxAge := c.opts.MaxStaleness
+	if r.loaded {
+		if c.opts.MaxAge <= 0 {
+			// loaded regardless of its age, so it is kept until the endpoint answers
+			return r.response, true
+		}
+		maxAge = max(maxAge, c.opts.MaxAge)
+	}
+	if maxAge <= 0 {
+		return response{}, false
+	}
+	if c.now().Sub(r.time) > maxAge {
+		delete(c.entries, name)
+		return response{}, false
+	}
+	return r.response, true
+}
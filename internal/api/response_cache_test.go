@@ -0,0 +1,62 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResponseCacheServesStale(t *testing.T) {
+	c := NewResponseCache(ResponseCacheOptions{MaxStaleness: 5 * time.Minute})
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	c.success("jobs", response{body: []byte(`{"jobs": []}`)})
+
+	now = now.Add(5 * time.Minute)
+	r, found := c.failure("jobs")
+	if !found || string(r.body) != `{"jobs": []}` {
+		t.Fatalf("expected the last good response within the max staleness, got %q", r.body)
+	}
+
+	now = now.Add(time.Second)
+	if _, found := c.failure("jobs"); found {
+		t.Fatalf("expected no response past the max staleness")
+	}
+	if _, found := c.failure("nodes"); found {
+		t.Fatalf("expected no response for an endpoint that never succeeded")
+	}
+}
+
+func TestResponseCacheWithoutStaleness(t *testing.T) {
+	c := NewResponseCache(ResponseCacheOptions{})
+	c.success("diag", response{body: []byte(`{}`)})
+	if _, found := c.failure("diag"); found {
+		t.Fatalf("expected no stale responses without a max staleness")
+	}
+}
+
+func TestResponseCacheTTL(t *testing.T) {
+	c := NewResponseCache(ResponseCacheOptions{TTLs: map[string]time.Duration{"shares": 5 * time.Minute}})
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	c.success("shares", response{body: []byte(`{"shares": {}}`)})
+	c.success("jobs", response{body: []byte(`{"jobs": []}`)})
+
+	now = now.Add(5*time.Minute - time.Second)
+	if r, found := c.fresh("shares"); !found || string(r.body) != `{"shares": {}}` {
+		t.Fatalf("expected the shares response to be reused within its ttl, got %q", r.body)
+	}
+	if _, found := c.fresh("jobs"); found {
+		t.Fatalf("expected no reuse for an endpoint without a ttl")
+	}
+
+	now = now.Add(time.Second)
+	if _, found := c.fresh("shares"); found {
+		t.Fatalf("expected the shares response to expire after its ttl")
+	}
+	// a ttl alone doesn't make the cache serve stale responses
+	if _, found := c.failure("shares"); found {
+		t.Fatalf("expected no stale responses without a max staleness")
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+// These are the version-agnostic shapes of the slurmrestd responses. Each
+// supported data parser version declares its own response types with the json
+// tags for that version (see responses_2311.go and friends), and those are
+// converted into these types after unmarshaling. Versioned types must keep
+// exactly the same fields so the conversion compiles, only the tags may differ.
+
+type DiagResp struct {
+	Statistics struct {
+		ServerThreadCount      *int32
+		AgentQueueSize         *int32
+		DbdAgentQueueSize      *int32
+		ScheduleCycleLast      *int32
+		ScheduleCycleMean      *int64
+		ScheduleCyclePerMinute *int64
+		BfDepthMean            *int64
+		BfCycleLast            *int32
+		BfCycleMean            *int64
+		BfBackfilledJobs       *int32
+		BfLastBackfilledJobs   *int32
+		BfBackfilledHetJobs    *int32
+	}
+}
+
+// JobResp and NodeResp are single records of the jobs and nodes lists. Those
+// lists can be huge, so they are decoded one record at a time instead of as a
+// whole response (see decodeRecords).
+
+type JobResp struct {
+	Account      *string
+	UserName     *string
+	Partition    *string
+	JobState     []string
+	Dependency   *string
+	JobResources struct {
+		Cpus *int32
+	}
+}
+
+type NodeResp struct {
+	Name          *string
+	Hostname      *string
+	State         []string
+	Tres          *string
+	TresUsed      *string
+	Partitions    []string
+	AllocMemory   *int64
+	RealMemory    *int64
+	AllocCpus     *int32
+	AllocIdleCpus *int32
+	Cpus          *int32
+}
+
+type PartitionsResp struct {
+	Partitions []struct {
+		Name *string
+		Cpus *struct {
+			Total *int32
+		}
+		Nodes *struct {
+			Configured *string
+		}
+	}
+}
+
+type SharesResp struct {
+	Shares struct {
+		Shares []struct {
+			Name           *string
+			EffectiveUsage *float64
+		}
+	}
+}
@@ -1,10 +1,43 @@
-//go:build 2405
-
 package api
 
-var apiVersion = "24.05"
+import (
+	"encoding/json"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+)
+
+// V0041 reads the responses of data parser v0.0.41, the default in slurm 24.05
+var V0041 = &Version{
+	Release: "24.05",
+	Parser:  "v0.0.41",
+	unmarshalDiag: func(b []byte) (DiagResp, error) {
+		var r V0041DiagResp
+		err := json.Unmarshal(b, &r)
+		return DiagResp(r), err
+	},
+	decodeJob: func(d *json.Decoder) (JobResp, error) {
+		var r V0041Job
+		err := d.Decode(&r)
+		return JobResp(r), err
+	},
+	decodeNode: func(d *json.Decoder) (NodeResp, error) {
+		var r V0041Node
+		err := d.Decode(&r)
+		return NodeResp(r), err
+	},
+	unmarshalPartitions: func(b []byte) (PartitionsResp, error) {
+		var r V0041PartitionsResp
+		err := json.Unmarshal(b, &r)
+		return PartitionsResp(r), err
+	},
+	unmarshalShares: func(b []byte) (SharesResp, error) {
+		var r V0041SharesResp
+		err := json.Unmarshal(util.CleanseInfinity(b), &r)
+		return SharesResp(r), err
+	},
+}
 
-type DiagResp struct {
+type V0041DiagResp struct {
 	Statistics struct {
 		ServerThreadCount      *int32 `json:"server_thread_count"`
 		AgentQueueSize         *int32 `json:"agent_queue_size"`
@@ -21,36 +54,32 @@ type DiagResp struct {
 	} `json:"statistics"`
 }
 
-type JobsResp struct {
-	Jobs []struct {
-		Account      *string  `json:"account"`
-		UserName     *string  `json:"user_name"`
-		Partition    *string  `json:"partition"`
-		JobState     []string `json:"job_state"`
-		Dependency   *string  `json:"dependency"`
-		JobResources struct {
-			Cpus *int32 `json:"cpus"`
-		} `json:"job_resources"`
-	} `json:"jobs"`
+type V0041Job struct {
+	Account      *string  `json:"account"`
+	UserName     *string  `json:"user_name"`
+	Partition    *string  `json:"partition"`
+	JobState     []string `json:"job_state"`
+	Dependency   *string  `json:"dependency"`
+	JobResources struct {
+		Cpus *int32 `json:"cpus"`
+	} `json:"job_resources"`
 }
 
-type NodesResp struct {
-	Nodes []struct {
-		Name          *string  `json:"name,omitempty"`
-		Hostname      *string  `json:"hostname,omitempty"`
-		State         []string `json:"state,omitempty"`
-		Tres          *string  `json:"tres,omitempty"`
-		TresUsed      *string  `json:"tres_used,omitempty"`
-		Partitions    []string `json:"partitions,omitempty"`
-		AllocMemory   *int64   `json:"alloc_memory,omitempty"`
-		RealMemory    *int64   `json:"real_memory,omitempty"`
-		AllocCpus     *int32   `json:"alloc_cpus,omitempty"`
-		AllocIdleCpus *int32   `json:"alloc_idle_cpus,omitempty"`
-		Cpus          *int32   `json:"cpus,omitempty"`
-	} `json:"nodes"`
+type V0041Node struct {
+	Name          *string  `json:"name,omitempty"`
+	Hostname      *string  `json:"hostname,omitempty"`
+	State         []string `json:"state,omitempty"`
+	Tres          *string  `json:"tres,omitempty"`
+	TresUsed      *string  `json:"tres_used,omitempty"`
+	Partitions    []string `json:"partitions,omitempty"`
+	AllocMemory   *int64   `json:"alloc_memory,omitempty"`
+	RealMemory    *int64   `json:"real_memory,omitempty"`
+	AllocCpus     *int32   `json:"alloc_cpus,omitempty"`
+	AllocIdleCpus *int32   `json:"alloc_idle_cpus,omitempty"`
+	Cpus          *int32   `json:"cpus,omitempty"`
 }
 
-type PartitionsResp struct {
+type V0041PartitionsResp struct {
 	Partitions []struct {
 		Name *string `json:"name,omitempty"`
 		Cpus *struct {
@@ -62,7 +91,7 @@ type PartitionsResp struct {
 	} `json:"partitions"`
 }
 
-type SharesResp struct {
+type V0041SharesResp struct {
 	Shares struct {
 		Shares []struct {
 			Name           *string  `json:"name"`
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// V0042 reads the responses of data parser v0.0.42, the default in slurm 24.11
+var V0042 = &Version{
+	Release: "24.11",
+	Parser:  "v0.0.42",
+	unmarshalDiag: func(b []byte) (DiagResp, error) {
+		var r V0042DiagResp
+		err := json.Unmarshal(b, &r)
+		return DiagResp(r), err
+	},
+	decodeJob: func(d *json.Decoder) (JobResp, error) {
+		var r V0042Job
+		err := d.Decode(&r)
+		return JobResp(r), err
+	},
+	decodeNode: func(d *json.Decoder) (NodeResp, error) {
+		var r V0042Node
+		err := d.Decode(&r)
+		return NodeResp(r), err
+	},
+	unmarshalPartitions: func(b []byte) (PartitionsResp, error) {
+		var r V0042PartitionsResp
+		err := json.Unmarshal(b, &r)
+		return PartitionsResp(r), err
+	},
+	unmarshalShares: func(b []byte) (SharesResp, error) {
+		var r V0042SharesResp
+		err := json.Unmarshal(b, &r)
+		return r.toSharesResp(), err
+	},
+}
+
+type V0042DiagResp struct {
+	Statistics struct {
+		ServerThreadCount      *int32 `json:"server_thread_count"`
+		AgentQueueSize         *int32 `json:"agent_queue_size"`
+		DbdAgentQueueSize      *int32 `json:"dbd_agent_queue_size"`
+		ScheduleCycleLast      *int32 `json:"schedule_cycle_last"`
+		ScheduleCycleMean      *int64 `json:"schedule_cycle_mean"`
+		ScheduleCyclePerMinute *int64 `json:"schedule_cycle_per_minute"`
+		BfDepthMean            *int64 `json:"bf_depth_mean"`
+		BfCycleLast            *int32 `json:"bf_cycle_last"`
+		BfCycleMean            *int64 `json:"bf_cycle_mean"`
+		BfBackfilledJobs       *int32 `json:"bf_backfilled_jobs"`
+		BfLastBackfilledJobs   *int32 `json:"bf_last_backfilled_jobs"`
+		BfBackfilledHetJobs    *int32 `json:"bf_backfilled_het_jobs"`
+	} `json:"statistics"`
+}
+
+type V0042Job struct {
+	Account      *string  `json:"account"`
+	UserName     *string  `json:"user_name"`
+	Partition    *string  `json:"partition"`
+	JobState     []string `json:"job_state"`
+	Dependency   *string  `json:"dependency"`
+	JobResources struct {
+		Cpus *int32 `json:"cpus"`
+	} `json:"job_resources"`
+}
+
+type V0042Node struct {
+	Name          *string  `json:"name,omitempty"`
+	Hostname      *string  `json:"hostname,omitempty"`
+	State         []string `json:"state,omitempty"`
+	Tres          *string  `json:"tres,omitempty"`
+	TresUsed      *string  `json:"tres_used,omitempty"`
+	Partitions    []string `json:"partitions,omitempty"`
+	AllocMemory   *int64   `json:"alloc_memory,omitempty"`
+	RealMemory    *int64   `json:"real_memory,omitempty"`
+	AllocCpus     *int32   `json:"alloc_cpus,omitempty"`
+	AllocIdleCpus *int32   `json:"alloc_idle_cpus,omitempty"`
+	Cpus          *int32   `json:"cpus,omitempty"`
+}
+
+type V0042PartitionsResp struct {
+	Partitions []struct {
+		Name *string `json:"name,omitempty"`
+		Cpus *struct {
+			Total *int32 `json:"total"`
+		} `json:"cpus"`
+		Nodes *struct {
+			Configured *string `json:"configured"`
+		} `json:"nodes"`
+	} `json:"partitions"`
+}
+
+type V0042SharesResp struct {
+	Shares struct {
+		Shares []struct {
+			Name           *string           `json:"name"`
+			EffectiveUsage V0042Float64NoVal `json:"effective_usage"`
+		} `json:"shares"`
+	} `json:"shares"`
+}
+
+// toSharesResp converts the response by hand, since the usage numbers are
+// wrapped in a different type than in the other versions
+func (r V0042SharesResp) toSharesResp() SharesResp {
+	var sr SharesResp
+	for _, s := range r.Shares.Shares {
+		sr.Shares.Shares = append(sr.Shares.Shares, struct {
+			Name           *string
+			EffectiveUsage *float64
+		}{s.Name, s.EffectiveUsage.Value()})
+	}
+	return sr
+}
+
+// V0042Float64NoVal is how v0.0.42 encodes numbers that may be unset or
+// infinite, instead of the bare "Infinity" older versions wrote into the json
+type V0042Float64NoVal struct {
+	Set      bool    `json:"set"`
+	Infinite bool    `json:"infinite"`
+	Number   float64 `json:"number"`
+}
+
+// Value returns the number, or nil if slurm did not set it. Infinite values
+// are reported as the largest float, the same value older versions produce.
+func (n V0042Float64NoVal) Value() *float64 {
+	if n.Infinite {
+		v := math.MaxFloat64
+		return &v
+	}
+	if !n.Set {
+		return nil
+	}
+	v := n.Number
+	return &v
+}
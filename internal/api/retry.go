@@ -0,0 +1,43 @@
+package api
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// RetryOptions configures how failed requests to slurmrestd are retried. Only
+// failures that are likely to be transient, like connection errors or a 500
+// from an overloaded controller, are retried.
+type RetryOptions struct {
+	// Retries is the number of attempts made after the first one fails
+	Retries  int
+	Backoff  time.Duration
+	MaxDelay time.Duration
+}
+
+// delay returns how long to wait before the given retry, starting at 0. The
+// backoff doubles with every retry up to MaxDelay, and is jittered so the
+// requests of a scrape don't all hit the controller at the same moment.
+func (o RetryOptions) delay(retry int) time.Duration {
+	d := o.Backoff
+	for i := 0; i < retry && d < o.MaxDelay; i++ {
+		d *= 2
+	}
+	if o.MaxDelay > 0 && d > o.MaxDelay {
+		d = o.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	// somewhere between half and all of the backoff
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryableStatus reports whether a response status is worth retrying
+func retryableStatus(code int) bool {
+	switch code {
+	case 500, 502, 503, 504:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+)
+
+// Snapshot holds the slurmrestd responses a scrape is answered from. Each
+// scrape gets its own, so concurrent scrapes never see each other's data.
+//
+// Every response is decoded at most once, the first time a collector asks for
+// it, and the result is shared by all collectors. The decoded data must be
+// treated as read-only.
+type Snapshot struct {
+	// Time is when the requests for the snapshot were started
+	Time time.Time
+	// Errors says why the endpoints missing from the snapshot failed
+	Errors EndpointErrors
+
+	responses  map[string]response
+	version    *Version
+	diag       decoded[DiagData]
+	jobs       decoded[JobsData]
+	nodes      decoded[NodesData]
+	partitions decoded[PartitionsData]
+	shares     decoded[SharesData]
+}
+
+// errNoSnapshot is returned in polling mode until the first poll has finished
+var errNoSnapshot = errors.New("no snapshot of slurmrestd responses yet")
+
+type decoded[T any] struct {
+	once sync.Once
+	data *T
+	err  error
+}
+
+// get decodes the response with process the first time it is called. For
+// responses that were decoded as they were read, streamed picks out the data.
+func (d *decoded[T]) get(s *Snapshot, name string, process func(*Version, []byte) (*T, error), streamed func(response) *T) (*T, error) {
+	d.once.Do(func() {
+		r, found := s.responses[name]
+		if !found {
+			if err := s.Errors[name]; err != nil {
+				d.err = fmt.Errorf("no %s response in snapshot: %v", name, err)
+				return
+			}
+			d.err = fmt.Errorf("no %s response in snapshot", name)
+			return
+		}
+		if streamed != nil && r.body == nil {
+			d.data = streamed(r)
+			return
+		}
+		d.data, d.err = process(s.version, r.body)
+	})
+	return d.data, d.err
+}
+
+// NewSnapshot returns a snapshot of the response bodies taken at t, decoded
+// with the data parser v
+func NewSnapshot(v *Version, t time.Time, bodies map[string][]byte) *Snapshot {
+	responses := make(map[string]response, len(bodies))
+	for name, b := range bodies {
+		responses[name] = response{body: b}
+	}
+	return newSnapshot(v, t, responses)
+}
+
+func newSnapshot(v *Version, t time.Time, responses map[string]response) *Snapshot {
+	return &Snapshot{Time: t, responses: responses, version: v}
+}
+
+// Has reports whether the snapshot has a response of the endpoint
+func (s *Snapshot) Has(name string) bool {
+	if s == nil {
+		return false
+	}
+	_, found := s.responses[name]
+	return found
+}
+
+// Endpoints returns the names of the endpoints the snapshot has responses of
+func (s *Snapshot) Endpoints() []string {
+	if s == nil {
+		return nil
+	}
+	var names []string
+	for name := range s.responses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Diag returns the decoded diag response
+func (s *Snapshot) Diag() (*DiagData, error) {
+	if s == nil {
+		return nil, errNoSnapshot
+	}
+	return s.diag.get(s, "diag", ProcessDiagResponse, nil)
+}
+
+// Jobs returns the decoded jobs response
+func (s *Snapshot) Jobs() (*JobsData, error) {
+	if s == nil {
+		return nil, errNoSnapshot
+	}
+	return s.jobs.get(s, "jobs", ProcessJobsResponse, func(r response) *JobsData { return r.jobs })
+}
+
+// Nodes returns the decoded nodes response
+func (s *Snapshot) Nodes() (*NodesData, error) {
+	if s == nil {
+		return nil, errNoSnapshot
+	}
+	return s.nodes.get(s, "nodes", ProcessNodesResponse, func(r response) *NodesData { return r.nodes })
+}
+
+// Partitions returns the decoded partitions response
+func (s *Snapshot) Partitions() (*PartitionsData, error) {
+	if s == nil {
+		return nil, errNoSnapshot
+	}
+	return s.partitions.get(s, "partitions", ProcessPartitionsResponse, nil)
+}
+
+// Shares returns the decoded shares response
+func (s *Snapshot) Shares() (*SharesData, error) {
+	if s == nil {
+		return nil, errNoSnapshot
+	}
+	return s.shares.get(s, "shares", ProcessSharesResponse, nil)
+}
+
+// takeSnapshot requests every endpoint and returns the responses as a snapshot.
+// Failed endpoints are logged and left out.
+func takeSnapshot(ctx context.Context) *Snapshot {
+	slog.Debug("taking snapshot")
+	started := time.Now()
+	responses, err := fetchResponses(ctx, false)
+	s := newSnapshot(ctx.Value(types.ApiVersionKey).(*Version), started, responses)
+	if err != nil {
+		slog.Error("error fetching slurmrestd responses", "target", targetName(ctx), "error", err)
+		errors.As(err, &s.Errors)
+	}
+	slog.Debug("finished taking snapshot", "duration", time.Since(started))
+	return s
+}
+
+// flight collapses concurrent snapshots into one. Scrapes that arrive while a
+// snapshot is being taken wait for it and share it, instead of sending their
+// own requests to slurmrestd.
+//
+// The snapshot is taken with a context of its own, so a scrape that goes away
+// doesn't cut it short for the others. It runs until the latest deadline of
+// the scrapes waiting for it, and is stopped once none of them are left.
+type flight struct {
+	mu   sync.Mutex
+	call *flightCall
+}
+
+type flightCall struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+	snapshot *Snapshot
+
+	// the fields below are guarded by the flight's mutex
+	waiters int
+	// timer stops the snapshot at deadline. It is nil when a waiting scrape
+	// has no deadline at all.
+	timer    *time.Timer
+	deadline time.Time
+}
+
+// do returns the snapshot being taken, starting one if there is none. It
+// gives up waiting once ctx is done.
+func (f *flight) do(ctx context.Context) *Snapshot {
+	c := f.join(ctx)
+	select {
+	case <-c.done:
+		return c.snapshot
+	case <-ctx.Done():
+		f.leave(c)
+		return abandonedSnapshot(ctx)
+	}
+}
+
+// join adds the scrape to the waiters of the snapshot being taken, or starts
+// a new one for it
+func (f *flight) join(ctx context.Context) *flightCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	deadline, hasDeadline := ctx.Deadline()
+	if c := f.call; c != nil && c.ctx.Err() == nil {
+		c.waiters++
+		// the snapshot has to last as long as the longest waiting scrape
+		if c.timer != nil {
+			if !hasDeadline {
+				c.timer.Stop()
+				c.timer = nil
+			} else if deadline.After(c.deadline) {
+				c.deadline = deadline
+				c.timer.Reset(time.Until(deadline))
+			}
+		}
+		return c
+	}
+
+	fetchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	c := &flightCall{ctx: fetchCtx, cancel: cancel, done: make(chan struct{}), waiters: 1}
+	if hasDeadline {
+		c.deadline = deadline
+		c.timer = time.AfterFunc(time.Until(deadline), cancel)
+	}
+	f.call = c
+	go f.take(c)
+	return c
+}
+
+// leave removes a scrape that gave up from the waiters, and stops the
+// snapshot when it was the last one
+func (f *flight) leave(c *flightCall) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c.waiters--
+	if c.waiters == 0 {
+		c.cancel()
+	}
+}
+
+func (f *flight) take(c *flightCall) {
+	c.snapshot = takeSnapshot(c.ctx)
+
+	f.mu.Lock()
+	if f.call == c {
+		f.call = nil
+	}
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	f.mu.Unlock()
+	c.cancel()
+	close(c.done)
+}
+
+// abandonedSnapshot is returned to a scrape that ended before the snapshot it
+// was waiting for was taken. Every endpoint is reported as failed.
+func abandonedSnapshot(ctx context.Context) *Snapshot {
+	s := NewSnapshot(ctx.Value(types.ApiVersionKey).(*Version), time.Now(), nil)
+	s.Errors = make(EndpointErrors)
+	for _, e := range enabledEndpoints(ctx) {
+		s.Errors[e.name] = fmt.Errorf("scrape ended while waiting for slurmrestd: %v", context.Cause(ctx))
+	}
+	return s
+}
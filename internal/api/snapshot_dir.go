@@ -0,0 +1,226 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The snapshot directory holds the last good response of every endpoint in
+// <endpoint>.json, as slurmrestd sent it. When the response was received is
+// kept as the file's modification time, and the data parser the responses
+// were recorded with is kept in data_parser.
+
+const versionFile = "data_parser"
+
+// persist writes the response of the endpoint to the snapshot directory
+func (c *ResponseCache) persist(name string, body []byte, t time.Time) error {
+	f, err := c.create(name)
+	if err != nil {
+		return err
+	}
+	f.Write(body)
+	return f.commit(t)
+}
+
+// snapshotFile is a response being written to the snapshot directory. It is
+// written to a temporary file first and renamed over the old one, so the
+// directory never holds a partial response, even if the exporter crashes.
+type snapshotFile struct {
+	f    *os.File
+	path string
+	// err is the first error writing failed with. Writes never fail, so a
+	// full disk doesn't stop a response from being read while it is written.
+	err error
+}
+
+// create starts writing a response of the endpoint to the snapshot
+// directory. It returns nil if there is no snapshot directory.
+func (c *ResponseCache) create(name string) (*snapshotFile, error) {
+	if c == nil || c.opts.Dir == "" {
+		return nil, nil
+	}
+	f, err := os.CreateTemp(c.opts.Dir, name+".json.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotFile{f: f, path: filepath.Join(c.opts.Dir, name+".json")}, nil
+}
+
+func (f *snapshotFile) Write(p []byte) (int, error) {
+	if f.err == nil {
+		_, f.err = f.f.Write(p)
+	}
+	return len(p), nil
+}
+
+// reset drops what was written so far, for a request that is retried
+func (f *snapshotFile) reset() {
+	if f.err == nil {
+		f.err = f.f.Truncate(0)
+	}
+	if f.err == nil {
+		_, f.err = f.f.Seek(0, io.SeekStart)
+	}
+}
+
+// commit replaces the endpoint's response in the snapshot directory with the
+// one written, received at t
+func (f *snapshotFile) commit(t time.Time) error {
+	tmp := f.f.Name()
+	err := f.err
+	if cerr := f.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, t, t)
+	}
+	if err == nil {
+		err = os.Rename(tmp, f.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// abort throws away what was written
+func (f *snapshotFile) abort() {
+	f.f.Close()
+	os.Remove(f.f.Name())
+}
+
+// Load reads the responses a previous run left in the snapshot directory, so
+// they can be served before slurmrestd has answered, or while it is down.
+// Responses older than the max age are removed instead, along with any
+// temporary files a crash left behind.
+func (c *ResponseCache) Load() error {
+	if c.opts.Dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(c.opts.Dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %v", err)
+	}
+	files, err := os.ReadDir(c.opts.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot directory: %v", err)
+	}
+
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range files {
+		path := filepath.Join(c.opts.Dir, f.Name())
+		if !f.Type().IsRegular() {
+			continue
+		}
+		if strings.Contains(f.Name(), ".tmp") {
+			os.Remove(path)
+			continue
+		}
+		if f.Name() == versionFile {
+			b, err := os.ReadFile(path)
+			if err == nil {
+				c.version, err = LookupVersion(string(b))
+			}
+			if err != nil {
+				slog.Warn("failed to read data parser of snapshot directory", "path", path, "error", err)
+			}
+			continue
+		}
+		name, found := strings.CutSuffix(f.Name(), ".json")
+		if !found {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			slog.Warn("failed to read response from snapshot directory", "path", path, "error", err)
+			continue
+		}
+		t := info.ModTime()
+		if c.opts.MaxAge > 0 && now.Sub(t) > c.opts.MaxAge {
+			slog.Debug("removing expired response from snapshot directory", "endpoint", name, "age", now.Sub(t))
+			os.Remove(path)
+			continue
+		}
+		body, err := os.ReadFile(path)
+		if err != nil {
+			slog.Warn("failed to read response from snapshot directory", "path", path, "error", err)
+			continue
+		}
+		c.entries[name] = cachedResponse{response: response{body: body}, time: t, expires: t.Add(c.opts.TTLs[name]), loaded: true}
+		slog.Info("loaded response from snapshot directory", "endpoint", name, "age", now.Sub(t))
+	}
+	return nil
+}
+
+// Version returns the data parser the responses in the snapshot directory
+// were recorded with, or nil if it isn't known. When slurmrestd can't be asked
+// at startup, the loaded responses can still be decoded with it.
+func (c *ResponseCache) Version() *Version {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) == 0 {
+		return nil
+	}
+	return c.version
+}
+
+// SetVersion sets the data parser the responses are decoded with, and records
+// it in the snapshot directory. Loaded responses recorded with another data
+// parser can't be decoded with it, so they are dropped.
+func (c *ResponseCache) SetVersion(v *Version) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version != nil && c.version != v {
+		for name, r := range c.entries {
+			if r.loaded {
+				slog.Info("dropping response recorded with another data parser", "endpoint", name, "parser", c.version.Parser)
+				delete(c.entries, name)
+			}
+		}
+	}
+	c.version = v
+	if c.opts.Dir == "" {
+		return nil
+	}
+	f, err := os.CreateTemp(c.opts.Dir, versionFile+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.WriteString(v.Parser + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(c.opts.Dir, versionFile))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to record data parser in snapshot directory: %v", err)
+	}
+	return nil
+}
+
+// saved returns every cached response, and when the oldest of them was received
+func (c *ResponseCache) saved() (map[string]response, time.Time) {
+	if c == nil {
+		return nil, time.Time{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	responses := make(map[string]response, len(c.entries))
+	var oldest time.Time
+	for name, r := range c.entries {
+		responses[name] = r.response
+		if oldest.IsZero() || r.time.Before(oldest) {
+			oldest = r.time
+		}
+	}
+	return responses, oldest
+}
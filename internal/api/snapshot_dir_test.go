@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+)
+
+func TestSnapshotDir(t *testing.T) {
+	dir := t.TempDir()
+	opts := ResponseCacheOptions{Dir: dir, MaxAge: time.Hour}
+	now := time.Unix(1700000000, 0)
+
+	c := NewResponseCache(opts)
+	c.now = func() time.Time { return now }
+	c.success("jobs", response{body: []byte(`{"jobs": []}`)})
+	now = now.Add(30 * time.Minute)
+	c.success("nodes", response{body: []byte(`{"nodes": []}`)})
+	if err := os.WriteFile(filepath.Join(dir, "shares.json.tmp123"), []byte(`{"sha`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// after a restart 45 minutes later the jobs response is too old
+	now = now.Add(45 * time.Minute)
+	c = NewResponseCache(opts)
+	c.now = func() time.Time { return now }
+	if err := c.Load(); err != nil {
+		t.Fatalf("failed to load snapshot directory: %v", err)
+	}
+	responses, oldest := c.saved()
+	if len(responses) != 1 || string(responses["nodes"].body) != `{"nodes": []}` {
+		t.Fatalf("expected only the nodes response to be loaded, got %v", responses)
+	}
+	if !oldest.Equal(time.Unix(1700000000, 0).Add(30 * time.Minute)) {
+		t.Fatalf("expected the nodes response to keep the time it was received, got %v", oldest)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 || files[0].Name() != "nodes.json" {
+		t.Fatalf("expected the old response and temporary file to be removed, got %v", files)
+	}
+
+	// the poller serves the loaded responses until its first poll
+	ctx := context.WithValue(context.Background(), types.ApiResponseCacheKey, c)
+	ctx = context.WithValue(ctx, types.ApiVersionKey, V0041)
+	p := NewPoller("", time.Minute)
+	p.seed(ctx)
+	if !p.Latest().Has("nodes") {
+		t.Fatalf("expected the poller to start with the loaded nodes response")
+	}
+}
+
+func TestSnapshotDirStandsInWhenScraping(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	// without a max staleness or ttls, the directory is all there is
+	opts := ResponseCacheOptions{Dir: dir, MaxAge: time.Hour}
+	now := time.Unix(1700000000, 0)
+	c := NewResponseCache(opts)
+	c.now = func() time.Time { return now }
+	c.success("jobs", response{body: util.ReadTestDataBytes("V0041OpenapiJobInfoResp.json")})
+
+	// slurmrestd is down when the exporter restarts
+	now = now.Add(30 * time.Minute)
+	c = NewResponseCache(opts)
+	c.now = func() time.Time { return now }
+	if err := c.Load(); err != nil {
+		t.Fatalf("failed to load snapshot directory: %v", err)
+	}
+	ctx := newTestContext(t, srv.URL, V0041)
+	ctx = context.WithValue(ctx, types.ApiEnabledEndpointsKey, []string{"jobs"})
+	ctx = context.WithValue(ctx, types.ApiResponseCacheKey, c)
+
+	s := takeSnapshot(ctx)
+	if s.Errors["jobs"] == nil {
+		t.Fatalf("expected the jobs request to be reported as failed")
+	}
+	if jobs, err := s.Jobs(); err != nil || len(jobs.Jobs) == 0 {
+		t.Fatalf("expected the loaded jobs response to stand in for the failed request: %v", err)
+	}
+
+	// past the max age it doesn't any more
+	now = now.Add(31 * time.Minute)
+	if s := takeSnapshot(ctx); s.Has("jobs") {
+		t.Fatalf("expected no jobs response past the max age")
+	}
+}
+
+func TestSnapshotDirVersion(t *testing.T) {
+	dir := t.TempDir()
+	opts := ResponseCacheOptions{Dir: dir}
+	c := NewResponseCache(opts)
+	if err := c.SetVersion(V0041); err != nil {
+		t.Fatalf("failed to record data parser: %v", err)
+	}
+	c.success("jobs", response{body: []byte(`{"jobs": []}`)})
+
+	// the loaded responses can be decoded without asking slurmrestd
+	c = NewResponseCache(opts)
+	if err := c.Load(); err != nil {
+		t.Fatalf("failed to load snapshot directory: %v", err)
+	}
+	if v := c.Version(); v != V0041 {
+		t.Fatalf("expected the data parser the responses were recorded with, got %v", v)
+	}
+
+	// after an upgrade they can't
+	if err := c.SetVersion(V0042); err != nil {
+		t.Fatalf("failed to record data parser: %v", err)
+	}
+	if responses, _ := c.saved(); len(responses) != 0 {
+		t.Fatalf("expected responses of another data parser to be dropped, got %v", responses)
+	}
+	b, _ := os.ReadFile(filepath.Join(dir, "data_parser"))
+	if string(b) != "v0.0.42\n" {
+		t.Fatalf("expected the new data parser to be recorded, got %q", b)
+	}
+}
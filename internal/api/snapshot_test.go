@@ -0,0 +1,30 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+)
+
+func TestSnapshotDecodesOnce(t *testing.T) {
+	s := NewSnapshot(V0041, time.Now(), map[string][]byte{
+		"jobs": util.ReadTestDataBytes("V0041OpenapiJobInfoResp.json"),
+	})
+	first, err := s.Jobs()
+	if err != nil {
+		t.Fatalf("failed to decode jobs: %v", err)
+	}
+	second, _ := s.Jobs()
+	if first != second {
+		t.Fatalf("expected the jobs response to be decoded once and shared")
+	}
+	if _, err := s.Nodes(); err == nil {
+		t.Fatalf("expected an error for a response missing from the snapshot")
+	}
+
+	var none *Snapshot
+	if _, err := none.Jobs(); err == nil {
+		t.Fatalf("expected an error without a snapshot")
+	}
+}
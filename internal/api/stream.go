@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// decodeRecords walks the top level object of a response and calls fn once
+// for every element of the list under key, with the decoder positioned at
+// that element. Only one record is decoded at a time, so the whole list never
+// has to exist as go values next to the converted data. Other keys are skipped.
+func decodeRecords(r io.Reader, key string, fn func(*json.Decoder) error) error {
+	d := json.NewDecoder(r)
+	if err := expectDelim(d, '{'); err != nil {
+		return err
+	}
+	for d.More() {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		if t != key {
+			var skip json.RawMessage
+			if err := d.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+		t, err = d.Token()
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			// an empty list may be sent as null
+			continue
+		}
+		if t != json.Delim('[') {
+			return fmt.Errorf("%s: expected [, found %v", key, t)
+		}
+		for d.More() {
+			if err := fn(d); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(d, ']'); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	return expectDelim(d, '}')
+}
+
+func expectDelim(d *json.Decoder, want json.Delim) error {
+	t, err := d.Token()
+	if err != nil {
+		return err
+	}
+	if t != want {
+		return fmt.Errorf("expected %v, found %v", want, t)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+)
+
+func TestDecodeRecords(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+		err  bool
+	}{
+		{"records", `{"meta": {"plugin": {}}, "jobs": [{"id": "a"}, {"id": "b"}], "errors": []}`, []string{"a", "b"}, false},
+		{"empty", `{"jobs": []}`, nil, false},
+		{"null", `{"jobs": null}`, nil, false},
+		{"missing", `{"errors": [], "warnings": []}`, nil, false},
+		{"not a list", `{"jobs": {"id": "a"}}`, nil, true},
+		{"truncated", `{"jobs": [{"id": "a"}, {"id": "b"`, []string{"a"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := decodeRecords(strings.NewReader(tt.body), "jobs", func(d *json.Decoder) error {
+				var r struct {
+					ID string `json:"id"`
+				}
+				if err := d.Decode(&r); err != nil {
+					return err
+				}
+				got = append(got, r.ID)
+				return nil
+			})
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("expected records %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDecodeJobsResponseSkipsInvalidJobs(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	job := `{"user_name": "user1", "account": "account1", "partition": "compute", "job_state": [%q], "dependency": "", "job_resources": {"cpus": 4}}`
+	body := `{"jobs": [` + fmt.Sprintf(job, "RUNNING") + "," + fmt.Sprintf(job, "TELEPORTING") + "," + fmt.Sprintf(job, "PENDING") + `]}`
+	d, err := DecodeJobsResponse(V0042, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to decode jobs response: %v", err)
+	}
+	if len(d.Jobs) != 2 || d.Jobs[0].JobState != types.JobStateRunning || d.Jobs[1].JobState != types.JobStatePending {
+		t.Fatalf("expected the running and pending jobs, got %+v", d.Jobs)
+	}
+	if !strings.Contains(logs.String(), "level=WARN") || !strings.Contains(logs.String(), "count=1") || !strings.Contains(logs.String(), "teleporting") {
+		t.Fatalf("expected a warning about the skipped job, got %q", logs.String())
+	}
+}
+
+const syntheticJobs = 200000
+
+// writeSyntheticJobs writes a v0.0.42 jobs response the size of a busy
+// cluster's, with a few of the fields the collectors don't use to pad out
+// every record like slurmrestd does
+func writeSyntheticJobs(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`{"jobs": [`)
+	for i := 0; i < syntheticJobs; i++ {
+		if i > 0 {
+			bw.WriteString(",")
+		}
+		state := "RUNNING"
+		if i%3 == 0 {
+			state = "PENDING"
+		}
+		fmt.Fprintf(bw, `{"job_id": %d, "name": "job-%d", "user_name": "user%d", "account": "account%d", "partition": "compute", "job_state": [%q], "dependency": "", "command": "/home/user%d/run.sh", "current_working_directory": "/home/user%d", "submit_time": {"set": true, "infinite": false, "number": 1700000000}, "job_resources": {"nodes": {"count": 1}, "cpus": %d}}`,
+			i, i, i%500, i%50, state, i%500, i%500, 1+i%64)
+	}
+	bw.WriteString(`], "last_update": {"set": true, "infinite": false, "number": 1700000000}, "meta": {}, "errors": [], "warnings": []}`)
+	return bw.Flush()
+}
+
+// The memory a decode of the jobs response may take per job. A job takes
+// about 360 bytes in the response, so holding the whole body would break
+// the bound on what is kept.
+const (
+	maxAllocatedPerJob = 1024
+	maxRetainedPerJob  = 256
+)
+
+// BenchmarkDecodeJobsResponse200k decodes 200k jobs as they arrive, like the
+// response from slurmrestd, and fails if the memory taken per job grows past
+// the bounds above
+func BenchmarkDecodeJobsResponse200k(b *testing.B) {
+	b.ReportAllocs()
+	var allocated, retained uint64
+	for i := 0; i < b.N; i++ {
+		r, w := io.Pipe()
+		go func() { w.CloseWithError(writeSyntheticJobs(w)) }()
+
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		d, err := DecodeJobsResponse(V0042, r)
+		if err != nil {
+			b.Fatal(err)
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		if len(d.Jobs) != syntheticJobs {
+			b.Fatalf("expected %d jobs, got %d", syntheticJobs, len(d.Jobs))
+		}
+		allocated += after.TotalAlloc - before.TotalAlloc
+		if after.HeapAlloc > before.HeapAlloc {
+			retained += after.HeapAlloc - before.HeapAlloc
+		}
+		runtime.KeepAlive(d)
+	}
+	perJob := func(n uint64) float64 { return float64(n) / float64(b.N*syntheticJobs) }
+	b.ReportMetric(perJob(allocated), "B/job")
+	b.ReportMetric(perJob(retained), "retained-B/job")
+	if perJob(allocated) > maxAllocatedPerJob {
+		b.Fatalf("decoding allocated %.0f bytes per job, more than the bound of %d", perJob(allocated), maxAllocatedPerJob)
+	}
+	if perJob(retained) > maxRetainedPerJob {
+		b.Fatalf("decoding kept %.0f bytes per job, more than the bound of %d", perJob(retained), maxRetainedPerJob)
+	}
+}
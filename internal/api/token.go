@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource provides the token sent to slurmrestd with each request
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// staticTokenSource always returns the token it was created with
+type staticTokenSource string
+
+// NewStaticTokenSource returns a TokenSource for a token that never changes,
+// such as one generated ahead of time with `scontrol token`. The token
+// metrics are labelled with target.
+func NewStaticTokenSource(target string, token string) TokenSource {
+	setTokenExpiry(target, token)
+	return staticTokenSource(token)
+}
+
+func (s staticTokenSource) Token() (string, error) {
+	return string(s), nil
+}
+
+// fileTokenSource reads the token from a file and reads it again whenever the
+// file changes, so the token can be rotated without restarting the exporter.
+type fileTokenSource struct {
+	path   string
+	target string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+}
+
+// NewFileTokenSource returns a TokenSource backed by the file at path. Relative
+// paths are resolved against $CREDENTIALS_DIRECTORY when it is set, so a
+// systemd LoadCredential name can be used directly. The token metrics are
+// labelled with target.
+func NewFileTokenSource(target string, path string) (TokenSource, error) {
+	credsDir, found := os.LookupEnv("CREDENTIALS_DIRECTORY")
+	if found && !filepath.IsAbs(path) {
+		path = filepath.Join(credsDir, path)
+	}
+	s := &fileTokenSource{path: path, target: target}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Token returns the token from the file, reloading it if the file changed.
+// If the reload fails, the previously loaded token keeps being used.
+func (s *fileTokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		slog.Error("failed to check api token file, using previous token", "path", s.path, "error", err)
+		return s.token, nil
+	}
+	if fi.ModTime().Equal(s.modTime) && fi.Size() == s.size {
+		return s.token, nil
+	}
+	if err := s.load(); err != nil {
+		slog.Error("failed to reload api token file, using previous token", "path", s.path, "error", err)
+	}
+	return s.token, nil
+}
+
+// load reads the token file. The caller must hold the lock once the source
+// is shared.
+func (s *fileTokenSource) load() error {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read api token file: %v", err)
+	}
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read api token file: %v", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return fmt.Errorf("api token file %s is empty", s.path)
+	}
+	s.token = token
+	s.modTime = fi.ModTime()
+	s.size = fi.Size()
+	tokenLastLoad.WithLabelValues(s.target).SetToCurrentTime()
+	setTokenExpiry(s.target, token)
+	slog.Debug("loaded api token from file", "path", s.path)
+	return nil
+}
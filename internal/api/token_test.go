@@ -0,0 +1,46 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileTokenSourceReload(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+	path := filepath.Join(dir, "slurm-token")
+	if err := os.WriteFile(path, []byte("first\n"), 0600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	// relative names resolve against the systemd credentials directory
+	ts, err := NewFileTokenSource("", "slurm-token")
+	if err != nil {
+		t.Fatalf("failed to create file token source: %v", err)
+	}
+	token, _ := ts.Token()
+	if token != "first" {
+		t.Fatalf("expected %q, got %q", "first", token)
+	}
+
+	if err := os.WriteFile(path, []byte("second\n"), 0600); err != nil {
+		t.Fatalf("failed to rotate token file: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	token, _ = ts.Token()
+	if token != "second" {
+		t.Fatalf("expected rotated token %q, got %q", "second", token)
+	}
+
+	// a broken rotation keeps the last good token
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove token file: %v", err)
+	}
+	token, _ = ts.Token()
+	if token != "second" {
+		t.Fatalf("expected previous token %q after failed reload, got %q", "second", token)
+	}
+}
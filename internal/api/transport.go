@@ -3,23 +3,36 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
 )
 
-// CleanseBaseURL removes any unneccessary elements from the provided url,
-// such as "http://", etc
+// CleanseBaseURL normalizes the provided url so endpoint paths can be appended
+// to it. The scheme is kept as given and defaults to "http://" when missing.
+// Socket paths in slurmrestd's own "unix:/path" form are rewritten to "unix:///path".
 func CleanseBaseURL(url string) string {
-	url = strings.ReplaceAll(url, "http://", "")
-	url = strings.ReplaceAll(url, "https://", "")
+	if strings.HasPrefix(url, "unix:") {
+		return "unix://" + strings.TrimPrefix(strings.TrimPrefix(url, "unix:"), "//")
+	}
+	url = strings.TrimRight(url, "/")
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
 	return url
 }
 
+// IsUnixSocketURL reports whether the api url points at a local slurmrestd socket
+func IsUnixSocketURL(url string) bool {
+	return strings.HasPrefix(url, "unix://")
+}
+
 type slurmRestRequest struct {
 	req    *http.Request
 	client *http.Client
@@ -30,8 +43,32 @@ type SlurmRestResponse struct {
 	Body       []byte
 }
 
-// GetSlurmRestResponse retrieves response data from slurm api
+// GetSlurmRestResponse retrieves response data from slurm api. Transient
+// failures are retried with backoff, and endpoints that keep failing are
+// skipped by their circuit breaker until the cool-down passes.
 func GetSlurmRestResponse(ctx context.Context, endpointCtxKey types.Key) ([]byte, error) {
+	return getSlurmRestResponse(ctx, endpointCtxKey, "")
+}
+
+// getSlurmRestResponse is GetSlurmRestResponse with a query string appended
+// to the endpoint path
+func getSlurmRestResponse(ctx context.Context, endpointCtxKey types.Key, query string) ([]byte, error) {
+	var body []byte
+	err := streamSlurmRestResponse(ctx, endpointCtxKey, query, func(r io.Reader) error {
+		var err error
+		body, err = io.ReadAll(r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// streamSlurmRestResponse performs the request like getSlurmRestResponse, but
+// hands the body of a successful response to read as it arrives, so it never
+// has to be held whole. read is called again for every retry.
+func streamSlurmRestResponse(ctx context.Context, endpointCtxKey types.Key, query string, read func(io.Reader) error) error {
 	var endpointStr string
 	switch endpointCtxKey {
 	case types.ApiDiagEndpointKey:
@@ -45,16 +82,87 @@ func GetSlurmRestResponse(ctx context.Context, endpointCtxKey types.Key) ([]byte
 	case types.ApiSharesEndpointKey:
 		endpointStr = "shares"
 	default:
-		return nil, fmt.Errorf("invalid endpoint key")
+		return fmt.Errorf("invalid endpoint key")
+	}
+
+	breakers, _ := ctx.Value(types.ApiBreakersKey).(*Breakers)
+	if breakers != nil {
+		if err := breakers.Allow(endpointStr); err != nil {
+			return err
+		}
+	}
+	retryOpts, _ := ctx.Value(types.ApiRetryOptionsKey).(RetryOptions)
+
+	o, err := getSlurmRestResponseRetried(ctx, endpointCtxKey, endpointStr, query, read, retryOpts)
+	if breakers != nil {
+		// only failures of the controller count against the breaker. an answer
+		// like a 401 still shows the endpoint is responding.
+		if o == answered {
+			breakers.Success(endpointStr)
+		} else {
+			breakers.Failure(endpointStr)
+		}
 	}
-	slog.Debug("performing rest request", "endpoint", endpointStr)
-	nr, err := newSlurmRestRequest(ctx, endpointCtxKey)
+	return err
+}
+
+// outcome is how a request to slurmrestd went, as far as retries and the
+// circuit breakers are concerned
+type outcome int
+
+const (
+	// answered means slurmrestd responded, successfully or with an error that
+	// won't go away by asking again, like a 401 or a body that doesn't decode
+	answered outcome = iota
+	// transient means the request failed in a way worth retrying
+	transient
+	// failed means the request failed and can't be retried, like when the
+	// scrape deadline has passed or no token could be had
+	failed
+)
+
+// getSlurmRestResponseRetried performs the request, retrying transient
+// failures with backoff. The outcome is that of the last attempt, or failed
+// if the deadline passed while waiting to retry.
+func getSlurmRestResponseRetried(ctx context.Context, endpointCtxKey types.Key, endpointStr string, query string, read func(io.Reader) error, retryOpts RetryOptions) (outcome, error) {
+	for attempt := 0; ; attempt++ {
+		o, err := getSlurmRestResponseOnce(ctx, endpointCtxKey, endpointStr, query, read)
+		if o != transient || attempt >= retryOpts.Retries {
+			return o, err
+		}
+		delay := retryOpts.delay(attempt)
+		slog.Debug("retrying slurm rest request", "endpoint", endpointStr, "attempt", attempt+1, "delay", delay, "error", err)
+		apiRetries.WithLabelValues(endpointStr, targetName(ctx)).Inc()
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return failed, fmt.Errorf("gave up retrying %s request: %v", endpointStr, err)
+		}
+	}
+}
+
+// getSlurmRestResponseOnce performs a single request, handing the body to read
+// if it succeeds, and reports its outcome
+func getSlurmRestResponseOnce(ctx context.Context, endpointCtxKey types.Key, endpointStr string, query string, read func(io.Reader) error) (outcome, error) {
+	slog.Debug("performing rest request", "endpoint", endpointStr, "query", query)
+	nr, err := newSlurmRestRequest(ctx, ctx.Value(endpointCtxKey).(string)+query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate new slurm rest request: %v", err)
+		return failed, fmt.Errorf("failed to generate new slurm rest request: %v", err)
+	}
+	resp, err := nr.stream(read)
+	var de *decodeError
+	if errors.As(err, &de) {
+		return answered, err
 	}
-	resp, err := nr.Send()
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve slurm rest response: %v", err)
+		// once the scrape deadline has passed there is no point in trying
+		// again, but a controller that doesn't answer in time still failed
+		if ctx.Err() != nil {
+			return failed, fmt.Errorf("failed to retrieve slurm rest response: %v", err)
+		}
+		return transient, fmt.Errorf("failed to retrieve slurm rest response: %v", err)
 	}
 	// sometimes slurm fails to get stuff. we want to error here
 	if resp.StatusCode == 500 {
@@ -66,44 +174,63 @@ func GetSlurmRestResponse(ctx context.Context, endpointCtxKey types.Key) ([]byte
 		err := json.Unmarshal(resp.Body, &aed)
 		if err != nil {
 			errStr = "tried to get more data about the error but failed. try debug mode for more information"
+		} else {
+			errStr = aed.ToString()
 		}
-		errStr = aed.ToString()
-		return nil, fmt.Errorf("internal server error (500) from slurm controller getting %s data: %s", endpointStr, errStr)
+		return transient, fmt.Errorf("internal server error (500) from slurm controller getting %s data: %s", endpointStr, errStr)
 	}
 	// unauthorized responses should say that
 	if resp.StatusCode == 401 {
-		return nil, fmt.Errorf("unauthorized: invalid credentials")
+		unauthorizedResponses.WithLabelValues(endpointStr, targetName(ctx)).Inc()
+		return answered, fmt.Errorf("unauthorized: invalid credentials")
 	}
 	// otherwise, it should be status 200, so this catches unsupported status codes
 	if resp.StatusCode != 200 {
 		slog.Debug("incorrect response status code", "endpoint", endpointStr, "code", resp.StatusCode, "body", string(resp.Body))
-		return nil, fmt.Errorf("received incorrect status code for %s data", endpointStr)
+		o := answered
+		if retryableStatus(resp.StatusCode) {
+			o = transient
+		}
+		return o, fmt.Errorf("received incorrect status code for %s data", endpointStr)
 	}
 	slog.Debug("successfully queried slurm rest data", "endpoint", endpointStr)
-	return resp.Body, nil
+	return answered, nil
 }
 
 // newSlurmRestRequest returns a new slurmRestRequest object which is used to perform
 // http interactions with the slurmrest server. It configures everything up until
-// the request is actually sent to get data.
-func newSlurmRestRequest(ctx context.Context, k types.Key) (*slurmRestRequest, error) {
-	apiUser := ctx.Value(types.ApiUserKey).(string)
-	apiToken := ctx.Value(types.ApiTokenKey).(string)
+// the request is actually sent to get data. The request is bound to ctx, so it is
+// abandoned once the scrape deadline passes.
+func newSlurmRestRequest(ctx context.Context, apiEndpoint string) (*slurmRestRequest, error) {
 	apiURL := ctx.Value(types.ApiURLKey).(string)
-	apiEndpoint := ctx.Value(k).(string)
 
-	url := fmt.Sprintf("http://%s/%s", apiURL, apiEndpoint)
-	req, err := http.NewRequest("GET", url, nil)
+	// requests over the socket are dialed by the transport, and recorded
+	// responses are read by it, so the host in the url is only a placeholder
+	url := fmt.Sprintf("%s%s", apiURL, apiEndpoint)
+	if IsUnixSocketURL(apiURL) || IsFileURL(apiURL) {
+		url = fmt.Sprintf("http://localhost%s", apiEndpoint)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("X-SLURM-USER-NAME", apiUser)
-	req.Header.Set("X-SLURM-USER-TOKEN", apiToken)
+	// slurmrestd authenticates socket connections with auth/local, and
+	// sending a token would make it try auth/jwt instead. Recorded responses
+	// need no authentication at all.
+	if !IsUnixSocketURL(apiURL) && !IsFileURL(apiURL) {
+		apiUser := ctx.Value(types.ApiUserKey).(string)
+		apiToken, err := ctx.Value(types.ApiTokenKey).(TokenSource).Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get api token: %v", err)
+		}
+		req.Header.Set("X-SLURM-USER-NAME", apiUser)
+		req.Header.Set("X-SLURM-USER-TOKEN", apiToken)
+	}
 
 	return &slurmRestRequest{
 		req:    req,
-		client: &http.Client{},
+		client: ctx.Value(types.ApiClientKey).(*http.Client),
 	}, nil
 }
 
@@ -111,20 +238,59 @@ func newSlurmRestRequest(ctx context.Context, k types.Key) (*slurmRestRequest, e
 // server. It returns a *SlurmRestResponse which is a struct containing the
 // response status code and the bytes of the response body.
 func (sr slurmRestRequest) Send() (*SlurmRestResponse, error) {
+	return sr.stream(nil)
+}
+
+// stream performs the request like Send, but if read is given, the body of a
+// 200 response is handed to it as it arrives instead of being returned. Other
+// responses are small error reports, and their bodies are returned whole. If
+// the body arrives but read fails on it, the error is a *decodeError.
+func (sr slurmRestRequest) stream(read func(io.Reader) error) (*SlurmRestResponse, error) {
 	resp, err := sr.client.Do(sr.req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %v", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	sresp := SlurmRestResponse{}
+	sresp.StatusCode = resp.StatusCode
+	body := &bodyReader{r: resp.Body}
+	if read != nil && resp.StatusCode == 200 {
+		if err := read(body); err != nil {
+			if body.err != nil {
+				return nil, fmt.Errorf("failed to read response body: %v", body.err)
+			}
+			return nil, &decodeError{err}
+		}
+		return &sresp, nil
+	}
+	sresp.Body, err = io.ReadAll(body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %v", err)
 	}
+	return &sresp, nil
+}
 
-	sresp := SlurmRestResponse{}
-	sresp.StatusCode = resp.StatusCode
-	sresp.Body = body
+// bodyReader remembers why reading the response body failed, to tell a
+// connection that broke off apart from a response that doesn't decode
+type bodyReader struct {
+	r   io.Reader
+	err error
+}
 
-	return &sresp, nil
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+// decodeError is returned for a response that arrived whole but could not be decoded
+type decodeError struct {
+	err error
+}
+
+func (e *decodeError) Error() string {
+	return e.err.Error()
 }
@@ -1,7 +1,19 @@
 package api
 
 import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
 )
 
 func TestCleanseBaseURL(t *testing.T) {
@@ -9,9 +21,12 @@ func TestCleanseBaseURL(t *testing.T) {
 		in   string
 		want string
 	}{
-		{"https://google.com", "google.com"},
-		{"http://google.com", "google.com"},
-		{"google.com", "google.com"},
+		{"https://google.com", "https://google.com"},
+		{"http://google.com", "http://google.com"},
+		{"google.com", "http://google.com"},
+		{"https://google.com:6820/", "https://google.com:6820"},
+		{"unix:/run/slurmrestd.sock", "unix:///run/slurmrestd.sock"},
+		{"unix:///run/slurmrestd.sock", "unix:///run/slurmrestd.sock"},
 	}
 	for _, tt := range tts {
 		t.Run(tt.in, func(t *testing.T) {
@@ -22,3 +37,168 @@ func TestCleanseBaseURL(t *testing.T) {
 		})
 	}
 }
+
+func TestGetSlurmRestResponseTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jobs": []}`))
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatalf("failed to write ca file: %v", err)
+	}
+	tlsConfig, err := NewTLSConfig(TLSOptions{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("failed to build tls config: %v", err)
+	}
+
+	// without the ca bundle the server certificate can't be verified
+	ctx := newTestContext(t, srv.URL, V0041)
+	if _, err := GetSlurmRestResponse(ctx, types.ApiJobsEndpointKey); err == nil {
+		t.Fatalf("expected certificate verification to fail without a ca bundle")
+	}
+
+	ctx = context.WithValue(ctx, types.ApiClientKey, NewHTTPClient(srv.URL, ClientOptions{TLSConfig: tlsConfig}))
+	b, err := GetSlurmRestResponse(ctx, types.ApiJobsEndpointKey)
+	if err != nil {
+		t.Fatalf("failed to get response over tls: %v", err)
+	}
+	if string(b) != `{"jobs": []}` {
+		t.Fatalf("unexpected response body: %s", b)
+	}
+}
+
+func TestNewTLSConfigRequiresCertAndKey(t *testing.T) {
+	_, err := NewTLSConfig(TLSOptions{CertFile: "client.pem"})
+	if err == nil {
+		t.Fatalf("expected an error when the client key is missing")
+	}
+}
+
+func TestGetSlurmRestResponseUnixSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "slurmrestd.sock")
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("failed to listen on unix socket: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-SLURM-USER-TOKEN") != "" || r.Header.Get("X-SLURM-USER-NAME") != "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"jobs": []}`))
+	}))
+	srv.Listener = l
+	srv.Start()
+	defer srv.Close()
+
+	ctx := context.Background()
+	apiURL := CleanseBaseURL("unix:" + socketPath)
+	ctx = context.WithValue(ctx, types.ApiURLKey, apiURL)
+	ctx = context.WithValue(ctx, types.ApiClientKey, NewHTTPClient(apiURL, ClientOptions{}))
+	ctx = context.WithValue(ctx, types.ApiJobsEndpointKey, "/slurm/v0.0.41/jobs")
+
+	b, err := GetSlurmRestResponse(ctx, types.ApiJobsEndpointKey)
+	if err != nil {
+		t.Fatalf("failed to get response over unix socket: %v", err)
+	}
+	if string(b) != `{"jobs": []}` {
+		t.Fatalf("unexpected response body: %s", b)
+	}
+}
+
+func TestGetSlurmRestResponseRetries(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"errors": [{"error": "Unable to contact slurm controller"}]}`))
+			return
+		}
+		w.Write([]byte(`{"jobs": []}`))
+	}))
+	defer srv.Close()
+
+	ctx := newTestContext(t, srv.URL, V0041)
+	ctx = context.WithValue(ctx, types.ApiRetryOptionsKey, RetryOptions{Retries: 2, Backoff: time.Millisecond})
+
+	b, err := GetSlurmRestResponse(ctx, types.ApiJobsEndpointKey)
+	if err != nil {
+		t.Fatalf("expected the request to succeed after retries: %v", err)
+	}
+	if string(b) != `{"jobs": []}` || calls != 3 {
+		t.Fatalf("unexpected result after %d calls: %s", calls, b)
+	}
+}
+
+func TestGetSlurmRestResponseInternalServerError(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"api errors", `{"errors": [{"error": "Unable to contact slurm controller"}]}`, "Unable to contact slurm controller"},
+		{"not json", `<html>Internal Server Error</html>`, "tried to get more data about the error but failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			ctx := newTestContext(t, srv.URL, V0041)
+			_, err := GetSlurmRestResponse(ctx, types.ApiJobsEndpointKey)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected an error about %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestGetSlurmRestResponseBreakerFailures(t *testing.T) {
+	// a controller that hangs past the scrape deadline
+	hang := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-hang:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(hang)
+
+	breakers := NewBreakers(BreakerOptions{Threshold: 1, Cooldown: time.Minute})
+	ctx := newTestContext(t, srv.URL, V0041)
+	ctx = context.WithValue(ctx, types.ApiBreakersKey, breakers)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := GetSlurmRestResponse(timeoutCtx, types.ApiJobsEndpointKey); err == nil {
+		t.Fatalf("expected the request to time out")
+	}
+	if err := breakers.Allow("jobs"); err == nil {
+		t.Fatalf("expected a timed out request to open the breaker")
+	}
+
+	// failing to get a token is not a success either
+	breakers = NewBreakers(BreakerOptions{Threshold: 1, Cooldown: time.Minute})
+	ctx = context.WithValue(ctx, types.ApiBreakersKey, breakers)
+	ctx = context.WithValue(ctx, types.ApiTokenKey, failingTokenSource{})
+	if _, err := GetSlurmRestResponse(ctx, types.ApiJobsEndpointKey); err == nil {
+		t.Fatalf("expected the request to fail without a token")
+	}
+	if err := breakers.Allow("jobs"); err == nil {
+		t.Fatalf("expected a token error to count as a failure")
+	}
+}
+
+type failingTokenSource struct{}
+
+func (failingTokenSource) Token() (string, error) {
+	return "", fmt.Errorf("token file is gone")
+}
@@ -1,90 +1,146 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
-
-	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func ProcessDiagResponse(b []byte) (*DiagData, error) {
-	var r DiagResp
+func ProcessDiagResponse(v *Version, b []byte) (*DiagData, error) {
 	if len(b) == 0 {
 		return nil, fmt.Errorf("failed to unmarshal diag response, body is empty")
 	}
-	err := json.Unmarshal(b, &r)
+	r, err := v.unmarshalDiag(b)
 	if err != nil {
 		slog.Debug("failed to unmarshal diag response", "body", string(b))
 		return nil, fmt.Errorf("failed to unmarshall diag response data: %v", err)
 	}
-	d := NewDiagData()
+	d := NewDiagData(v.Release)
 	d.FromResponse(r)
 	return d, nil
 }
 
 // ProcessJobsResponse converts the response bytes into a slurm type
-func ProcessJobsResponse(b []byte) (*JobsData, error) {
-	var r JobsResp
+func ProcessJobsResponse(v *Version, b []byte) (*JobsData, error) {
 	if len(b) == 0 {
 		return nil, fmt.Errorf("failed to unmarshal jobs response, body is empty")
 	}
-	err := json.Unmarshal(b, &r)
+	d, err := DecodeJobsResponse(v, bytes.NewReader(b))
 	if err != nil {
 		slog.Debug("failed to unmarshal jobs response", "body", string(b))
+		return nil, err
+	}
+	return d, nil
+}
+
+// DecodeJobsResponse converts the response read from r into a slurm type. The
+// jobs are decoded and converted one at a time as they are read, so only the
+// fields the collectors use are held for each of them.
+func DecodeJobsResponse(v *Version, r io.Reader) (*JobsData, error) {
+	d := NewJobsData(v.Release)
+	var skipped skippedRecords
+	err := decodeRecords(r, "jobs", func(dec *json.Decoder) error {
+		j, err := v.decodeJob(dec)
+		if err != nil {
+			return err
+		}
+		skipped.add(d.AddJob(j))
+		return nil
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshall jobs response data: %v", err)
 	}
-	d := NewJobsData()
-	d.FromResponse(r)
+	skipped.log("jobs")
 	return d, nil
 }
 
 // ProcessNodesResponse converts the response bytes into a slurm type
-func ProcessNodesResponse(b []byte) (*NodesData, error) {
-	var r NodesResp
+func ProcessNodesResponse(v *Version, b []byte) (*NodesData, error) {
 	if len(b) == 0 {
 		return nil, fmt.Errorf("failed to unmarshal nodes response, body is empty")
 	}
-	err := json.Unmarshal(b, &r)
+	d, err := DecodeNodesResponse(v, bytes.NewReader(b))
 	if err != nil {
 		slog.Debug("failed to unmarshal nodes response", "body", string(b))
+		return nil, err
+	}
+	return d, nil
+}
+
+// DecodeNodesResponse converts the response read from r into a slurm type,
+// one node at a time like DecodeJobsResponse
+func DecodeNodesResponse(v *Version, r io.Reader) (*NodesData, error) {
+	d := NewNodesData(v.Release)
+	var skipped skippedRecords
+	err := decodeRecords(r, "nodes", func(dec *json.Decoder) error {
+		n, err := v.decodeNode(dec)
+		if err != nil {
+			return err
+		}
+		skipped.add(d.AddNode(n))
+		return nil
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshall nodes response data: %v", err)
 	}
-	d := NewNodesData()
-	d.FromResponse(r)
+	skipped.log("nodes")
 	return d, nil
 }
 
+// skippedRecords counts the records of a response that could not be
+// converted, which are left out of the metrics instead of failing the scrape
+type skippedRecords struct {
+	count int
+	first error
+}
+
+func (s *skippedRecords) add(err error) {
+	if err == nil {
+		return
+	}
+	if s.count == 0 {
+		s.first = err
+	}
+	s.count++
+}
+
+// log warns about the skipped records once per response, with the reason the
+// first one was skipped
+func (s *skippedRecords) log(kind string) {
+	if s.count > 0 {
+		slog.Warn("skipped records that could not be converted", "kind", kind, "count", s.count, "error", s.first)
+	}
+}
+
 // ProcessPartitionsResponse converts the response bytes into a slurm type
-func ProcessPartitionsResponse(b []byte) (*PartitionsData, error) {
-	var r PartitionsResp
+func ProcessPartitionsResponse(v *Version, b []byte) (*PartitionsData, error) {
 	if len(b) == 0 {
 		return nil, fmt.Errorf("failed to unmarshal partitions response, body is empty")
 	}
-	err := json.Unmarshal(b, &r)
+	r, err := v.unmarshalPartitions(b)
 	if err != nil {
 		slog.Debug("failed to unmarshal partitions response", "body", string(b))
 		return nil, fmt.Errorf("failed to unmarshall partitions response data: %v", err)
 	}
-	d := NewPartitionsData()
+	d := NewPartitionsData(v.Release)
 	d.FromResponse(r)
 	return d, nil
 }
 
 // ProcessSharesResponse converts the response bytes into a slurm type
-func ProcessSharesResponse(b []byte) (*SharesData, error) {
-	b = util.CleanseInfinity(b)
-	var r SharesResp
+func ProcessSharesResponse(v *Version, b []byte) (*SharesData, error) {
 	if len(b) == 0 {
 		return nil, fmt.Errorf("failed to unmarshal shares response, body is empty")
 	}
-	err := json.Unmarshal(b, &r)
+	r, err := v.unmarshalShares(b)
 	if err != nil {
 		slog.Debug("failed to unmarshal shares response", "body", string(b))
 		return nil, fmt.Errorf("failed to unmarshall shares response data: %v", err)
 	}
 
-	d := NewSharesData()
+	d := NewSharesData(v.Release)
 	d.FromResponse(r)
 	return d, nil
 }
@@ -1,5 +1,3 @@
-//go:build 2311
-
 package api
 
 import (
@@ -9,8 +7,8 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestUnmarshalDiagResponse(t *testing.T) {
-	var r DiagResp
+func TestUnmarshalDiagResponse2311(t *testing.T) {
+	var r V0040DiagResp
 	fb := util.ReadTestDataBytes("V0040OpenapiDiagResp.json")
 	err := json.Unmarshal(fb, &r)
 	if err != nil {
@@ -18,26 +16,24 @@ func TestUnmarshalDiagResponse(t *testing.T) {
 	}
 }
 
-func TestUnmarshalJobsResponse(t *testing.T) {
-	var r JobsResp
+func TestUnmarshalJobsResponse2311(t *testing.T) {
 	fb := util.ReadTestDataBytes("V0040OpenapiJobInfoResp.json")
-	err := json.Unmarshal(fb, &r)
+	_, err := ProcessJobsResponse(V0040, fb)
 	if err != nil {
 		t.Fatalf("failed to unmarshal jobs response: %v\n", err)
 	}
 }
 
-func TestUnmarshalNodesResponse(t *testing.T) {
-	var r NodesResp
+func TestUnmarshalNodesResponse2311(t *testing.T) {
 	fb := util.ReadTestDataBytes("V0040OpenapiNodesResp.json")
-	err := json.Unmarshal(fb, &r)
+	_, err := ProcessNodesResponse(V0040, fb)
 	if err != nil {
 		t.Fatalf("failed to unmarshal nodes response: %v\n", err)
 	}
 }
 
-func TestUnmarshalPartitionsResponse(t *testing.T) {
-	var r PartitionsResp
+func TestUnmarshalPartitionsResponse2311(t *testing.T) {
+	var r V0040PartitionsResp
 	fb := util.ReadTestDataBytes("V0040OpenapiPartitionResp.json")
 	err := json.Unmarshal(fb, &r)
 	if err != nil {
@@ -45,8 +41,8 @@ func TestUnmarshalPartitionsResponse(t *testing.T) {
 	}
 }
 
-func TestUnmarshalSharesResponse(t *testing.T) {
-	var r SharesResp
+func TestUnmarshalSharesResponse2311(t *testing.T) {
+	var r V0040SharesResp
 	fb := util.ReadTestDataBytes("V0040OpenapiSharesResp.json")
 	fb = util.CleanseInfinity(fb)
 	err := json.Unmarshal(fb, &r)
@@ -1,5 +1,3 @@
-//go:build 2405
-
 package api
 
 import (
@@ -9,35 +7,33 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestUnmarshalDiagResponse(t *testing.T) {
-	var r DiagResp
-	fb := util.ReadTestDataBytes("SlurmV0041GetDiag200Response.json")
+func TestUnmarshalDiagResponse2405(t *testing.T) {
+	var r V0041DiagResp
+	fb := util.ReadTestDataBytes("V0041OpenapiDiagResp.json")
 	err := json.Unmarshal(fb, &r)
 	if err != nil {
 		t.Fatalf("failed to unmarshal diag response: %v\n", err)
 	}
 }
 
-func TestUnmarshalJobsResponse(t *testing.T) {
-	var r JobsResp
+func TestUnmarshalJobsResponse2405(t *testing.T) {
 	fb := util.ReadTestDataBytes("V0041OpenapiJobInfoResp.json")
-	err := json.Unmarshal(fb, &r)
+	_, err := ProcessJobsResponse(V0041, fb)
 	if err != nil {
 		t.Fatalf("failed to unmarshal jobs response: %v\n", err)
 	}
 }
 
-func TestUnmarshalNodesResponse(t *testing.T) {
-	var r NodesResp
+func TestUnmarshalNodesResponse2405(t *testing.T) {
 	fb := util.ReadTestDataBytes("V0041OpenapiNodesResp.json")
-	err := json.Unmarshal(fb, &r)
+	_, err := ProcessNodesResponse(V0041, fb)
 	if err != nil {
 		t.Fatalf("failed to unmarshal nodes response: %v\n", err)
 	}
 }
 
-func TestUnmarshalPartitionsResponse(t *testing.T) {
-	var r PartitionsResp
+func TestUnmarshalPartitionsResponse2405(t *testing.T) {
+	var r V0041PartitionsResp
 	fb := util.ReadTestDataBytes("V0041OpenapiPartitionResp.json")
 	err := json.Unmarshal(fb, &r)
 	if err != nil {
@@ -45,8 +41,8 @@ func TestUnmarshalPartitionsResponse(t *testing.T) {
 	}
 }
 
-func TestUnmarshalSharesResponse(t *testing.T) {
-	var r SharesResp
+func TestUnmarshalSharesResponse2405(t *testing.T) {
+	var r V0041SharesResp
 	fb := util.ReadTestDataBytes("V0041OpenapiSharesResp.json")
 	fb = util.CleanseInfinity(fb)
 	err := json.Unmarshal(fb, &r)
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+)
+
+func TestUnmarshalDiagResponse2411(t *testing.T) {
+	var r V0042DiagResp
+	fb := util.ReadTestDataBytes("V0042OpenapiDiagResp.json")
+	err := json.Unmarshal(fb, &r)
+	if err != nil {
+		t.Fatalf("failed to unmarshal diag response: %v\n", err)
+	}
+}
+
+func TestUnmarshalJobsResponse2411(t *testing.T) {
+	fb := util.ReadTestDataBytes("V0042OpenapiJobInfoResp.json")
+	_, err := ProcessJobsResponse(V0042, fb)
+	if err != nil {
+		t.Fatalf("failed to unmarshal jobs response: %v\n", err)
+	}
+}
+
+func TestUnmarshalNodesResponse2411(t *testing.T) {
+	fb := util.ReadTestDataBytes("V0042OpenapiNodesResp.json")
+	_, err := ProcessNodesResponse(V0042, fb)
+	if err != nil {
+		t.Fatalf("failed to unmarshal nodes response: %v\n", err)
+	}
+}
+
+func TestUnmarshalPartitionsResponse2411(t *testing.T) {
+	var r V0042PartitionsResp
+	fb := util.ReadTestDataBytes("V0042OpenapiPartitionResp.json")
+	err := json.Unmarshal(fb, &r)
+	if err != nil {
+		t.Fatalf("failed to unmarshal partition response: %v\n", err)
+	}
+}
+
+func TestUnmarshalSharesResponse2411(t *testing.T) {
+	var r V0042SharesResp
+	fb := util.ReadTestDataBytes("V0042OpenapiSharesResp.json")
+	err := json.Unmarshal(fb, &r)
+	if err != nil {
+		t.Fatalf("failed to unmarshal shares response: %v\n", err)
+	}
+}
+
+func TestFloat64NoValValue2411(t *testing.T) {
+	tts := []struct {
+		name string
+		in   V0042Float64NoVal
+		want *float64
+	}{
+		{"set", V0042Float64NoVal{Set: true, Number: 0.5}, ptr(0.5)},
+		{"unset", V0042Float64NoVal{Number: 0.5}, nil},
+		{"infinite", V0042Float64NoVal{Set: true, Infinite: true}, ptr(math.MaxFloat64)},
+	}
+	for _, tt := range tts {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.in.Value()
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func ptr(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Version describes a slurmrestd data parser the exporter can read. The
+// endpoint paths and response layouts both depend on it, so it is picked
+// once at startup, either from configuration or by asking slurmrestd.
+type Version struct {
+	// Release is the slurm release the data parser shipped with, e.g. "24.05"
+	Release string
+	// Parser is the data parser version used in the endpoint paths, e.g. "v0.0.41"
+	Parser string
+
+	unmarshalDiag       func([]byte) (DiagResp, error)
+	decodeJob           func(*json.Decoder) (JobResp, error)
+	decodeNode          func(*json.Decoder) (NodeResp, error)
+	unmarshalPartitions func([]byte) (PartitionsResp, error)
+	unmarshalShares     func([]byte) (SharesResp, error)
+}
+
+// SupportedVersions lists the data parsers this exporter can read, newest first
+var SupportedVersions = []*Version{V0042, V0041, V0040}
+
+func (v *Version) String() string {
+	return fmt.Sprintf("%s (data parser %s)", v.Release, v.Parser)
+}
+
+// LookupVersion finds a supported version by slurm release ("24.05" or "2405")
+// or by data parser ("v0.0.41" or "0.0.41")
+func LookupVersion(s string) (*Version, error) {
+	s = strings.TrimSpace(s)
+	for _, v := range SupportedVersions {
+		switch s {
+		case v.Release, strings.ReplaceAll(v.Release, ".", ""), v.Parser, strings.TrimPrefix(v.Parser, "v"):
+			return v, nil
+		}
+	}
+	var known []string
+	for _, v := range SupportedVersions {
+		known = append(known, v.Release)
+	}
+	return nil, fmt.Errorf("unsupported slurm version %q, supported versions are %s", s, strings.Join(known, ", "))
+}
+
+// DetectVersion asks slurmrestd which data parsers it serves by pinging each
+// supported version, newest first. slurmrestd answers 404 for paths of data
+// parsers it does not have loaded, and any other answer means the parser is
+// there, even if slurmctld itself is not responding.
+func DetectVersion(ctx context.Context) (*Version, error) {
+	for _, v := range SupportedVersions {
+		path := fmt.Sprintf("/slurm/%s/ping", v.Parser)
+		nr, err := newSlurmRestRequest(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate version probe request: %v", err)
+		}
+		resp, err := nr.Send()
+		if err != nil {
+			return nil, fmt.Errorf("failed to probe slurmrestd for data parser %s: %v", v.Parser, err)
+		}
+		switch resp.StatusCode {
+		case 404:
+			slog.Debug("data parser not available", "parser", v.Parser)
+			continue
+		case 401:
+			return nil, fmt.Errorf("unauthorized: invalid credentials")
+		}
+		slog.Debug("detected data parser", "parser", v.Parser, "code", resp.StatusCode)
+		return v, nil
+	}
+	return nil, fmt.Errorf("slurmrestd does not serve any supported data parser")
+}
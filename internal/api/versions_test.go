@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLookupVersion(t *testing.T) {
+	tts := []struct {
+		in   string
+		want *Version
+	}{
+		{"23.11", V0040},
+		{"2405", V0041},
+		{"v0.0.40", V0040},
+		{"0.0.41", V0041},
+	}
+	for _, tt := range tts {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := LookupVersion(tt.in)
+			if err != nil {
+				t.Fatalf("failed to look up version: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+	if _, err := LookupVersion("20.11"); err == nil {
+		t.Fatalf("expected an error for an unsupported version")
+	}
+}
+
+func TestDetectVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/slurm/v0.0.40/ping" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"pings": []}`))
+	}))
+	defer srv.Close()
+
+	ctx := newTestContext(t, srv.URL, nil)
+
+	v, err := DetectVersion(ctx)
+	if err != nil {
+		t.Fatalf("failed to detect version: %v", err)
+	}
+	if v != V0040 {
+		t.Fatalf("detected %s, want %s", v, V0040)
+	}
+}
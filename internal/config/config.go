@@ -0,0 +1,621 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/slurm"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting of the exporter. It is built from the defaults,
+// then the config file, then the SLURM_EXPORTER_* environment variables, and
+// finally the command line flags, each overriding what came before.
+type Config struct {
+	ListenAddress string    `yaml:"listen_address"`
+	Debug         bool      `yaml:"debug"`
+	TLS           ListenTLS `yaml:"tls"`
+	API           API       `yaml:"api"`
+	// Targets are further slurmrestd servers to export the metrics of
+	Targets []Target `yaml:"targets"`
+
+	// ScrapeTimeoutOffset is how much of the prometheus scrape timeout to
+	// leave for answering the scrape
+	ScrapeTimeoutOffset time.Duration `yaml:"scrape_timeout_offset"`
+	// PollInterval turns on polling mode when set
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// MaxStaleness is how long the last good responses stand in for failed requests
+	MaxStaleness time.Duration `yaml:"max_staleness"`
+	Snapshot     Snapshot      `yaml:"snapshot"`
+
+	// Collectors turns collectors on or off by name
+	Collectors map[string]bool `yaml:"collectors"`
+	// Pseudonyms replaces user and account label values when set up
+	Pseudonyms Pseudonyms `yaml:"pseudonyms"`
+	// Cardinality limits the series of per-user, per-account, per-partition
+	// and per-node metrics
+	Cardinality Cardinality `yaml:"cardinality"`
+}
+
+// ListenTLS configures TLS for the exporter's own listener
+type ListenTLS struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// API configures the connection to slurmrestd
+type API struct {
+	URL     string `yaml:"url"`
+	User    string `yaml:"user"`
+	Version string `yaml:"version"`
+
+	// only one of these is used, in this order
+	JWTKeyFile  string        `yaml:"jwt_key_file"`
+	JWTLifespan time.Duration `yaml:"jwt_lifespan"`
+	TokenFile   string        `yaml:"token_file"`
+	Token       string        `yaml:"token"`
+
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+
+	DialTimeout     time.Duration `yaml:"dial_timeout"`
+	TLSTimeout      time.Duration `yaml:"tls_timeout"`
+	ResponseTimeout time.Duration `yaml:"response_timeout"`
+
+	Retries          int           `yaml:"retries"`
+	RetryBackoff     time.Duration `yaml:"retry_backoff"`
+	RetryMaxBackoff  time.Duration `yaml:"retry_max_backoff"`
+	BreakerThreshold int           `yaml:"breaker_threshold"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown"`
+
+	Incremental       bool          `yaml:"incremental"`
+	IncrementalResync time.Duration `yaml:"incremental_resync"`
+
+	// TTLs maps endpoint names to how long their responses are reused
+	TTLs map[string]time.Duration `yaml:"ttls"`
+}
+
+// Target is a slurmrestd server besides the one under api, scraped with
+// /metrics?target=<name>
+type Target struct {
+	Name string `yaml:"name"`
+	// API holds the settings that differ from those under api, which the
+	// target gets for everything else. It is decoded with TargetAPI, once
+	// the settings under api are complete.
+	API yaml.Node `yaml:"api"`
+}
+
+// Snapshot configures the directory the last good responses are kept in
+type Snapshot struct {
+	Dir    string        `yaml:"dir"`
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+// Pseudonyms configures replacing user and account label values with pseudonyms
+type Pseudonyms struct {
+	KeyFile string `yaml:"key_file"`
+	// Socket is where admins can look up the names behind the pseudonyms
+	Socket string `yaml:"socket"`
+}
+
+// Cardinality configures which label values of per-user, per-account,
+// per-partition and per-node metrics get their own series
+type Cardinality struct {
+	Users      LabelFilter `yaml:"users"`
+	Accounts   LabelFilter `yaml:"accounts"`
+	Partitions LabelFilter `yaml:"partitions"`
+	Nodes      LabelFilter `yaml:"nodes"`
+
+	TopUsers    int `yaml:"top_users"`
+	TopAccounts int `yaml:"top_accounts"`
+}
+
+// LabelFilter holds regexes that are matched against whole label values
+type LabelFilter struct {
+	Include string `yaml:"include"`
+	Exclude string `yaml:"exclude"`
+}
+
+// compile returns the filter with its regexes compiled
+func (f LabelFilter) compile() (slurm.LabelFilter, error) {
+	var lf slurm.LabelFilter
+	var err error
+	if f.Include != "" {
+		if lf.Include, err = regexp.Compile("^(?:" + f.Include + ")$"); err != nil {
+			return lf, fmt.Errorf("include: %v", err)
+		}
+	}
+	if f.Exclude != "" {
+		if lf.Exclude, err = regexp.Compile("^(?:" + f.Exclude + ")$"); err != nil {
+			return lf, fmt.Errorf("exclude: %v", err)
+		}
+	}
+	return lf, nil
+}
+
+// Limits returns the cardinality limits for the collectors. The regexes must
+// have been checked with Validate.
+func (c Cardinality) Limits() *slurm.Cardinality {
+	users, _ := c.Users.compile()
+	accounts, _ := c.Accounts.compile()
+	partitions, _ := c.Partitions.compile()
+	nodes, _ := c.Nodes.compile()
+	return &slurm.Cardinality{
+		Users:       users,
+		Accounts:    accounts,
+		Partitions:  partitions,
+		Nodes:       nodes,
+		TopUsers:    c.TopUsers,
+		TopAccounts: c.TopAccounts,
+	}
+}
+
+// Endpoints are the names of the slurmrestd endpoints the exporter reads
+var Endpoints = []string{"jobs", "nodes", "partitions", "diag", "shares"}
+
+// Default returns the configuration used for everything that isn't set
+func Default() *Config {
+	collectors := make(map[string]bool)
+	for _, c := range slurm.Collectors {
+		collectors[c.Name] = true
+	}
+	return &Config{
+		ListenAddress:       "0.0.0.0:8080",
+		ScrapeTimeoutOffset: 500 * time.Millisecond,
+		API: API{
+			JWTLifespan:       30 * time.Minute,
+			DialTimeout:       5 * time.Second,
+			TLSTimeout:        10 * time.Second,
+			ResponseTimeout:   30 * time.Second,
+			Retries:           2,
+			RetryBackoff:      200 * time.Millisecond,
+			RetryMaxBackoff:   5 * time.Second,
+			BreakerThreshold:  5,
+			BreakerCooldown:   time.Minute,
+			IncrementalResync: 10 * time.Minute,
+			TTLs:              map[string]time.Duration{},
+		},
+		Snapshot: Snapshot{
+			MaxAge: time.Hour,
+		},
+		Collectors: collectors,
+	}
+}
+
+// Errors lists every problem found in a configuration
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = "  - " + err.Error()
+	}
+	return "invalid configuration:\n" + strings.Join(msgs, "\n")
+}
+
+// LoadFile reads the YAML config file at path over c. Unknown keys are
+// errors, so typos don't go unnoticed.
+func (c *Config) LoadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	d := yaml.NewDecoder(bytes.NewReader(b))
+	d.KnownFields(true)
+	if err := d.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		var te *yaml.TypeError
+		if errors.As(err, &te) {
+			var errs Errors
+			for _, msg := range te.Errors {
+				errs = append(errs, fmt.Errorf("%s: %s", path, msg))
+			}
+			return errs
+		}
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// ApplyEnv overrides c with the SLURM_EXPORTER_* variables that lookup finds.
+// Values that fail to parse are all returned, rather than only the first.
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) Errors {
+	var errs Errors
+	str := func(name string, v *string) {
+		if s, found := lookup(name); found {
+			*v = s
+		}
+	}
+	boolean := func(name string, v *bool) {
+		s, found := lookup(name)
+		if !found {
+			return
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse %s. Please set to 1, t, T, TRUE, true, True, 0, f, F, FALSE, false, or False", name))
+			return
+		}
+		*v = b
+	}
+	duration := func(name string, v *time.Duration) {
+		s, found := lookup(name)
+		if !found {
+			return
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse %s. Please use a duration such as 30s or 5m", name))
+			return
+		}
+		*v = d
+	}
+	integer := func(name string, v *int) {
+		s, found := lookup(name)
+		if !found {
+			return
+		}
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse %s. Please use a whole number of 0 or more", name))
+			return
+		}
+		*v = i
+	}
+
+	str("SLURM_EXPORTER_LISTEN_ADDRESS", &c.ListenAddress)
+	// debug logging is turned on by the variable being set at all
+	if _, found := lookup("SLURM_EXPORTER_DEBUG"); found {
+		c.Debug = true
+	}
+	boolean("SLURM_EXPORTER_ENABLE_TLS", &c.TLS.Enabled)
+	str("SLURM_EXPORTER_TLS_CERT_PATH", &c.TLS.CertFile)
+	str("SLURM_EXPORTER_TLS_KEY_PATH", &c.TLS.KeyFile)
+
+	str("SLURM_EXPORTER_API_URL", &c.API.URL)
+	str("SLURM_EXPORTER_API_USER", &c.API.User)
+	str("SLURM_EXPORTER_API_VERSION", &c.API.Version)
+	str("SLURM_EXPORTER_API_JWT_KEY_FILE", &c.API.JWTKeyFile)
+	duration("SLURM_EXPORTER_API_JWT_LIFESPAN", &c.API.JWTLifespan)
+	str("SLURM_EXPORTER_API_TOKEN_FILE", &c.API.TokenFile)
+	str("SLURM_EXPORTER_API_TOKEN", &c.API.Token)
+	str("SLURM_EXPORTER_API_CA_FILE", &c.API.CAFile)
+	str("SLURM_EXPORTER_API_CERT_FILE", &c.API.CertFile)
+	str("SLURM_EXPORTER_API_KEY_FILE", &c.API.KeyFile)
+	str("SLURM_EXPORTER_API_SERVER_NAME", &c.API.ServerName)
+	boolean("SLURM_EXPORTER_API_INSECURE_SKIP_VERIFY", &c.API.InsecureSkipVerify)
+	duration("SLURM_EXPORTER_API_DIAL_TIMEOUT", &c.API.DialTimeout)
+	duration("SLURM_EXPORTER_API_TLS_TIMEOUT", &c.API.TLSTimeout)
+	duration("SLURM_EXPORTER_API_RESPONSE_TIMEOUT", &c.API.ResponseTimeout)
+	integer("SLURM_EXPORTER_API_RETRIES", &c.API.Retries)
+	duration("SLURM_EXPORTER_API_RETRY_BACKOFF", &c.API.RetryBackoff)
+	duration("SLURM_EXPORTER_API_RETRY_MAX_BACKOFF", &c.API.RetryMaxBackoff)
+	integer("SLURM_EXPORTER_API_BREAKER_THRESHOLD", &c.API.BreakerThreshold)
+	duration("SLURM_EXPORTER_API_BREAKER_COOLDOWN", &c.API.BreakerCooldown)
+	boolean("SLURM_EXPORTER_API_INCREMENTAL", &c.API.Incremental)
+	duration("SLURM_EXPORTER_API_INCREMENTAL_RESYNC", &c.API.IncrementalResync)
+	for _, name := range Endpoints {
+		env := "SLURM_EXPORTER_API_" + strings.ToUpper(name) + "_TTL"
+		if _, found := lookup(env); !found {
+			continue
+		}
+		ttl := c.API.TTLs[name]
+		duration(env, &ttl)
+		if c.API.TTLs == nil {
+			c.API.TTLs = make(map[string]time.Duration)
+		}
+		c.API.TTLs[name] = ttl
+	}
+
+	duration("SLURM_EXPORTER_SCRAPE_TIMEOUT_OFFSET", &c.ScrapeTimeoutOffset)
+	duration("SLURM_EXPORTER_POLL_INTERVAL", &c.PollInterval)
+	duration("SLURM_EXPORTER_MAX_STALENESS", &c.MaxStaleness)
+	str("SLURM_EXPORTER_SNAPSHOT_DIR", &c.Snapshot.Dir)
+	duration("SLURM_EXPORTER_SNAPSHOT_MAX_AGE", &c.Snapshot.MaxAge)
+	for _, collector := range slurm.Collectors {
+		env := "SLURM_EXPORTER_COLLECTOR_" + strings.ToUpper(collector.Name)
+		if _, found := lookup(env); !found {
+			continue
+		}
+		enabled := c.Collectors[collector.Name]
+		boolean(env, &enabled)
+		if c.Collectors == nil {
+			c.Collectors = make(map[string]bool)
+		}
+		c.Collectors[collector.Name] = enabled
+	}
+	str("SLURM_EXPORTER_PSEUDONYM_KEY_FILE", &c.Pseudonyms.KeyFile)
+	str("SLURM_EXPORTER_PSEUDONYM_SOCKET", &c.Pseudonyms.Socket)
+	filters := []struct {
+		env    string
+		filter *LabelFilter
+	}{
+		{"USERS", &c.Cardinality.Users},
+		{"ACCOUNTS", &c.Cardinality.Accounts},
+		{"PARTITIONS", &c.Cardinality.Partitions},
+		{"NODES", &c.Cardinality.Nodes},
+	}
+	for _, f := range filters {
+		str("SLURM_EXPORTER_CARDINALITY_"+f.env+"_INCLUDE", &f.filter.Include)
+		str("SLURM_EXPORTER_CARDINALITY_"+f.env+"_EXCLUDE", &f.filter.Exclude)
+	}
+	integer("SLURM_EXPORTER_CARDINALITY_TOP_USERS", &c.Cardinality.TopUsers)
+	integer("SLURM_EXPORTER_CARDINALITY_TOP_ACCOUNTS", &c.Cardinality.TopAccounts)
+	return errs
+}
+
+// sets reports whether the target has its own value for the api setting
+func (t Target) sets(key string) bool {
+	for i := 0; i+1 < len(t.API.Content); i += 2 {
+		if t.API.Content[i].Value == key {
+			return t.API.Content[i+1].Value != ""
+		}
+	}
+	return false
+}
+
+// TargetAPI returns the api settings of the target, which are those under api
+// with the ones set for the target on top. Unknown keys are errors, like in
+// the config file itself.
+func (c *Config) TargetAPI(t Target) (API, error) {
+	a := c.API
+	a.TTLs = maps.Clone(c.API.TTLs)
+	if t.API.IsZero() {
+		return a, nil
+	}
+	if t.API.Kind != yaml.MappingNode {
+		return a, fmt.Errorf("line %d: api must be a mapping", t.API.Line)
+	}
+	known := make(map[string]bool)
+	at := reflect.TypeOf(a)
+	for i := 0; i < at.NumField(); i++ {
+		known[at.Field(i).Tag.Get("yaml")] = true
+	}
+	var errs Errors
+	for i := 0; i < len(t.API.Content); i += 2 {
+		if key := t.API.Content[i]; !known[key.Value] {
+			errs = append(errs, fmt.Errorf("line %d: field %s not found in type config.API", key.Line, key.Value))
+		}
+	}
+	if err := t.API.Decode(&a); err != nil {
+		var te *yaml.TypeError
+		if !errors.As(err, &te) {
+			return a, err
+		}
+		for _, msg := range te.Errors {
+			errs = append(errs, errors.New(msg))
+		}
+	}
+	if len(errs) > 0 {
+		return a, errs
+	}
+	return a, nil
+}
+
+// Validate checks the configuration as a whole and returns every problem it
+// finds, or nil if there are none
+func (c *Config) Validate() error {
+	var errs Errors
+	problem := func(format string, a ...any) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	if c.ListenAddress == "" {
+		problem("listen_address must not be empty")
+	}
+	if c.TLS.Enabled {
+		if c.TLS.CertFile == "" {
+			problem("tls.cert_file (SLURM_EXPORTER_TLS_CERT_PATH) is required when tls is enabled")
+		}
+		if c.TLS.KeyFile == "" {
+			problem("tls.key_file (SLURM_EXPORTER_TLS_KEY_PATH) is required when tls is enabled")
+		}
+	}
+
+	// without api.url there is no default target, only the listed ones
+	if c.API.URL == "" && len(c.Targets) == 0 {
+		problem("api.url (SLURM_EXPORTER_API_URL) is required, for example localhost:6820")
+	}
+	c.API.validate("api", true, problem)
+
+	names := make(map[string]bool)
+	for i, t := range c.Targets {
+		switch {
+		case t.Name == "":
+			problem("targets[%d].name is required", i)
+			continue
+		case names[t.Name]:
+			problem("targets[%d].name: %q is used by another target", i, t.Name)
+			continue
+		case strings.ContainsAny(t.Name, `/\`) || t.Name == "." || t.Name == "..":
+			// the name is also the target's directory under snapshot.dir
+			problem("targets[%d].name: %q must not be a path", i, t.Name)
+			continue
+		}
+		names[t.Name] = true
+		prefix := fmt.Sprintf("targets[%s].api", t.Name)
+		a, err := c.TargetAPI(t)
+		if errs, ok := err.(Errors); ok {
+			for _, err := range errs {
+				problem("%s: %v", prefix, err)
+			}
+			continue
+		} else if err != nil {
+			problem("%s: %v", prefix, err)
+			continue
+		}
+		// inheriting api.url would scrape the default target under another name
+		if !t.sets("url") {
+			problem("%s.url is required", prefix)
+		}
+		a.validate(prefix, false, problem)
+	}
+
+	durations := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"scrape_timeout_offset", c.ScrapeTimeoutOffset},
+		{"poll_interval", c.PollInterval},
+		{"max_staleness", c.MaxStaleness},
+		{"snapshot.max_age", c.Snapshot.MaxAge},
+	}
+	for _, d := range durations {
+		if d.d < 0 {
+			problem("%s must not be negative, got %s", d.name, d.d)
+		}
+	}
+	filters := []struct {
+		name   string
+		filter LabelFilter
+	}{
+		{"users", c.Cardinality.Users},
+		{"accounts", c.Cardinality.Accounts},
+		{"partitions", c.Cardinality.Partitions},
+		{"nodes", c.Cardinality.Nodes},
+	}
+	for _, f := range filters {
+		if _, err := f.filter.compile(); err != nil {
+			problem("cardinality.%s.%v", f.name, err)
+		}
+	}
+	if c.Cardinality.TopUsers < 0 {
+		problem("cardinality.top_users must be 0 or more, got %d", c.Cardinality.TopUsers)
+	}
+	if c.Cardinality.TopAccounts < 0 {
+		problem("cardinality.top_accounts must be 0 or more, got %d", c.Cardinality.TopAccounts)
+	}
+	if c.Pseudonyms.Socket != "" && c.Pseudonyms.KeyFile == "" {
+		problem("pseudonyms.socket needs pseudonyms.key_file to be set")
+	}
+	var collectors []string
+	for name := range c.Collectors {
+		collectors = append(collectors, name)
+	}
+	sort.Strings(collectors)
+	for _, name := range collectors {
+		if !isCollector(name) {
+			var known []string
+			for _, collector := range slurm.Collectors {
+				known = append(known, collector.Name)
+			}
+			problem("collectors: unknown collector %q, collectors are %s", name, strings.Join(known, ", "))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validate checks the api settings found under prefix. The environment
+// variables are only named in the problems when env is set, since targets
+// can't be set from the environment.
+func (a API) validate(prefix string, env bool, problem func(format string, a ...any)) {
+	key := func(name string, variables string) string {
+		if env {
+			return fmt.Sprintf("%s.%s (%s)", prefix, name, variables)
+		}
+		return prefix + "." + name
+	}
+
+	if a.URL != "" {
+		if u := api.CleanseBaseURL(a.URL); !api.IsUnixSocketURL(u) && !api.IsFileURL(u) {
+			// slurmrestd authenticates unix socket connections itself with
+			// auth/local, and recorded responses need no authentication, so
+			// the user and token are only required for network connections
+			if a.User == "" {
+				problem("%s is required", key("user", "SLURM_EXPORTER_API_USER"))
+			}
+			if a.JWTKeyFile == "" && a.TokenFile == "" && a.Token == "" {
+				tokens := fmt.Sprintf("one of %[1]s.token, %[1]s.token_file or %[1]s.jwt_key_file", prefix)
+				if env {
+					tokens += " (SLURM_EXPORTER_API_TOKEN, SLURM_EXPORTER_API_TOKEN_FILE or SLURM_EXPORTER_API_JWT_KEY_FILE)"
+				}
+				problem("%s is required", tokens)
+			}
+		}
+	}
+	if a.Version != "" {
+		if _, err := api.LookupVersion(a.Version); err != nil {
+			problem("%s.version: %v", prefix, err)
+		}
+	}
+	if (a.CertFile == "") != (a.KeyFile == "") {
+		problem("%[1]s.cert_file and %[1]s.key_file must be set together", prefix)
+	}
+
+	durations := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"jwt_lifespan", a.JWTLifespan},
+		{"dial_timeout", a.DialTimeout},
+		{"tls_timeout", a.TLSTimeout},
+		{"response_timeout", a.ResponseTimeout},
+		{"retry_backoff", a.RetryBackoff},
+		{"retry_max_backoff", a.RetryMaxBackoff},
+		{"breaker_cooldown", a.BreakerCooldown},
+		{"incremental_resync", a.IncrementalResync},
+	}
+	for _, d := range durations {
+		if d.d < 0 {
+			problem("%s.%s must not be negative, got %s", prefix, d.name, d.d)
+		}
+	}
+	if a.JWTKeyFile != "" && a.JWTLifespan == 0 {
+		problem("%s.jwt_lifespan must be more than 0 when signing tokens", prefix)
+	}
+	if a.Retries < 0 {
+		problem("%s.retries must be 0 or more, got %d", prefix, a.Retries)
+	}
+	if a.BreakerThreshold < 0 {
+		problem("%s.breaker_threshold must be 0 or more, got %d", prefix, a.BreakerThreshold)
+	}
+
+	var names []string
+	for name := range a.TTLs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !isEndpoint(name) {
+			problem("%s.ttls: unknown endpoint %q, endpoints are %s", prefix, name, strings.Join(Endpoints, ", "))
+		} else if a.TTLs[name] < 0 {
+			problem("%s.ttls.%s must not be negative, got %s", prefix, name, a.TTLs[name])
+		}
+	}
+}
+
+func isCollector(name string) bool {
+	for _, c := range slurm.Collectors {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isEndpoint(name string) bool {
+	for _, e := range Endpoints {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
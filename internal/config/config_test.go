@@ -0,0 +1,281 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func lookupIn(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, found := env[name]
+		return v, found
+	}
+}
+
+func TestExampleConfig(t *testing.T) {
+	c := Default()
+	if err := c.LoadFile("../../extras/config.yml"); err != nil {
+		t.Fatalf("failed to load the example config: %v", err)
+	}
+	c.API.Token = "token"
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected the example config to be valid: %v", err)
+	}
+	// the example lists the defaults, so it must not change any of them
+	c.API.URL, c.API.User, c.API.Token = "", "", ""
+	d := Default()
+	if c.ListenAddress != d.ListenAddress || c.API.Retries != d.API.Retries || c.Snapshot.MaxAge != d.Snapshot.MaxAge || c.API.ResponseTimeout != d.API.ResponseTimeout {
+		t.Fatalf("expected the example config to keep the defaults")
+	}
+}
+
+func TestEnvOverridesFile(t *testing.T) {
+	path := writeConfig(t, `
+api:
+  url: head1:6820
+  user: slurm
+  token: from-file
+  retries: 4
+  ttls:
+    shares: 5m
+poll_interval: 30s
+`)
+	c := Default()
+	if err := c.LoadFile(path); err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	errs := c.ApplyEnv(lookupIn(map[string]string{
+		"SLURM_EXPORTER_API_TOKEN":       "from-env",
+		"SLURM_EXPORTER_API_JOBS_TTL":    "30s",
+		"SLURM_EXPORTER_POLL_INTERVAL":   "1m",
+		"SLURM_EXPORTER_DEBUG":           "",
+		"SLURM_EXPORTER_API_INCREMENTAL": "true",
+	}))
+	if len(errs) > 0 {
+		t.Fatalf("failed to apply environment: %v", errs)
+	}
+	if c.API.Token != "from-env" || c.API.Retries != 4 || c.PollInterval != time.Minute || !c.Debug || !c.API.Incremental {
+		t.Fatalf("unexpected configuration after applying the environment: %+v", c)
+	}
+	if c.API.TTLs["shares"] != 5*time.Minute || c.API.TTLs["jobs"] != 30*time.Second {
+		t.Fatalf("expected ttls from both the file and the environment, got %v", c.API.TTLs)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected a valid configuration: %v", err)
+	}
+}
+
+func TestLoadFileListsEveryProblem(t *testing.T) {
+	path := writeConfig(t, `
+api:
+  url: head1:6820
+  retries: many
+  tokn: abc
+listen_adress: ":8080"
+`)
+	err := Default().LoadFile(path)
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("expected 3 problems, got %v", err)
+	}
+}
+
+func TestApplyEnvListsEveryProblem(t *testing.T) {
+	errs := Default().ApplyEnv(lookupIn(map[string]string{
+		"SLURM_EXPORTER_ENABLE_TLS":       "maybe",
+		"SLURM_EXPORTER_API_RETRIES":      "a few",
+		"SLURM_EXPORTER_API_SHARES_TTL":   "5",
+		"SLURM_EXPORTER_API_DIAL_TIMEOUT": "5s",
+		"SLURM_EXPORTER_API_INCREMENTAL":  "yes",
+		"SLURM_EXPORTER_SNAPSHOT_MAX_AGE": "1h",
+	}))
+	if len(errs) != 4 {
+		t.Fatalf("expected 4 problems, got %v", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "SLURM_EXPORTER_ENABLE_TLS") {
+		t.Fatalf("expected the tls variable to be reported first, got %v", errs[0])
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c := Default()
+	c.TLS.Enabled = true
+	c.API.URL = "head1:6820"
+	c.API.Version = "22.05"
+	c.API.CertFile = "client.crt"
+	c.API.TTLs["share"] = time.Minute
+	c.PollInterval = -time.Second
+	c.Pseudonyms.Socket = "/run/prometheus-slurm-exporter/pseudonyms.sock"
+	err := c.Validate()
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("expected problems, got %v", err)
+	}
+	for _, want := range []string{"tls.cert_file", "tls.key_file", "api.user", "api.token", "api.version", "api.cert_file", "poll_interval", `unknown endpoint "share"`, "pseudonyms.socket"} {
+		if !strings.Contains(errs.Error(), want) {
+			t.Errorf("expected a problem about %s in:\n%v", want, errs)
+		}
+	}
+
+	// unix sockets are authenticated by slurmrestd itself
+	c = Default()
+	c.API.URL = "unix:///run/slurmrestd.sock"
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected a socket url to need no credentials: %v", err)
+	}
+
+	c = Default()
+	c.API.URL = "file:///var/tmp/fixtures"
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected a file url to need no credentials: %v", err)
+	}
+}
+
+func TestTargets(t *testing.T) {
+	path := writeConfig(t, `
+api:
+  user: slurm
+  token: default-token
+  retries: 4
+  ttls:
+    shares: 5m
+targets:
+  - name: cluster2
+    api:
+      url: head2:6820
+      token: cluster2-token
+      ttls:
+        jobs: 30s
+`)
+	c := Default()
+	if err := c.LoadFile(path); err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	// the targets get the settings under api from the environment too
+	c.ApplyEnv(lookupIn(map[string]string{"SLURM_EXPORTER_API_USER": "exporter"}))
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected targets without a default one to be valid: %v", err)
+	}
+	a, err := c.TargetAPI(c.Targets[0])
+	if err != nil {
+		t.Fatalf("failed to get the target's api settings: %v", err)
+	}
+	if a.URL != "head2:6820" || a.Token != "cluster2-token" || a.User != "exporter" || a.Retries != 4 {
+		t.Fatalf("unexpected target api settings: %+v", a)
+	}
+	if a.TTLs["shares"] != 5*time.Minute || a.TTLs["jobs"] != 30*time.Second {
+		t.Fatalf("expected ttls from both api and the target, got %v", a.TTLs)
+	}
+	if _, found := c.API.TTLs["jobs"]; found {
+		t.Fatalf("expected the target's ttls to leave those under api alone, got %v", c.API.TTLs)
+	}
+
+	path = writeConfig(t, `
+api:
+  url: head1:6820
+  user: slurm
+  token: default-token
+targets:
+  - name: cluster2
+    api:
+      token: cluster2-token
+  - name: cluster2
+    api:
+      url: head3:6820
+  - api:
+      url: head4:6820
+  - name: cluster5
+    api:
+      url: head5:6820
+      tokn: abc
+      retries: -1
+`)
+	c = Default()
+	if err := c.LoadFile(path); err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	errs, ok := c.Validate().(Errors)
+	if !ok {
+		t.Fatalf("expected problems with the targets")
+	}
+	for _, want := range []string{"targets[cluster2].api.url is required", `targets[1].name: "cluster2" is used`, "targets[2].name is required", "targets[cluster5].api: line 18: field tokn not found"} {
+		if !strings.Contains(errs.Error(), want) {
+			t.Errorf("expected a problem about %s in:\n%v", want, errs)
+		}
+	}
+}
+
+func TestCollectors(t *testing.T) {
+	path := writeConfig(t, `
+api:
+  url: unix:///run/slurmrestd.sock
+collectors:
+  fairshare: false
+  queue: false
+`)
+	c := Default()
+	if err := c.LoadFile(path); err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if errs := c.ApplyEnv(lookupIn(map[string]string{"SLURM_EXPORTER_COLLECTOR_QUEUE": "true"})); len(errs) > 0 {
+		t.Fatalf("failed to apply environment: %v", errs)
+	}
+	if c.Collectors["fairshare"] || !c.Collectors["queue"] || !c.Collectors["users"] {
+		t.Fatalf("unexpected collectors: %v", c.Collectors)
+	}
+
+	c.Collectors["fairshar"] = false
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), `unknown collector "fairshar"`) {
+		t.Fatalf("expected an unknown collector to be a problem, got %v", err)
+	}
+}
+
+func TestCardinality(t *testing.T) {
+	path := writeConfig(t, `
+api:
+  url: unix:///run/slurmrestd.sock
+cardinality:
+  users:
+    exclude: svc-.*
+  nodes:
+    include: n\d+
+  top_users: 50
+`)
+	c := Default()
+	if err := c.LoadFile(path); err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if errs := c.ApplyEnv(lookupIn(map[string]string{"SLURM_EXPORTER_CARDINALITY_ACCOUNTS_INCLUDE": "phys|bio"})); len(errs) > 0 {
+		t.Fatalf("failed to apply environment: %v", errs)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected a valid configuration: %v", err)
+	}
+	l := c.Cardinality.Limits()
+	if l.TopUsers != 50 || l.Users.Keep("svc-backup") || !l.Users.Keep("alice") {
+		t.Fatalf("unexpected user limits: %+v", l)
+	}
+	// regexes match whole label values
+	if l.Nodes.Keep("gpu01") || !l.Nodes.Keep("n0101") || l.Accounts.Keep("physics") || !l.Accounts.Keep("bio") {
+		t.Fatalf("expected regexes to match whole label values")
+	}
+
+	c.Cardinality.Partitions.Exclude = "gpu["
+	c.Cardinality.TopAccounts = -1
+	err := c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "cardinality.partitions.exclude") || !strings.Contains(err.Error(), "cardinality.top_accounts") {
+		t.Fatalf("expected problems with the partitions regex and top accounts, got %v", err)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"log/slog"
 
-	"github.com/akyoto/cache"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
@@ -48,37 +47,35 @@ func (ac *AccountsCollector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (ac *AccountsCollector) Collect(ch chan<- prometheus.Metric) {
-	apiCache := ac.ctx.Value(types.ApiCacheKey).(*cache.Cache)
-	jobsRespBytes, found := apiCache.Get("jobs")
-	if !found {
-		slog.Error("failed to get jobs response for users metrics from cache")
-		return
-	}
-	jobsData, err := api.ProcessJobsResponse(jobsRespBytes.([]byte))
+	snapshot := ac.ctx.Value(types.ApiSnapshotKey).(*api.Snapshot)
+	jobsData, err := snapshot.Jobs()
 	if err != nil {
 		slog.Error("failed to extract jobs data for accounts metrics", "error", err)
 		return
 	}
-	am, err := ParseAccountsMetrics(*jobsData)
+	cardinality, _ := ac.ctx.Value(types.CardinalityKey).(*Cardinality)
+	am, err := ParseAccountsMetrics(*jobsData, cardinality)
 	if err != nil {
 		slog.Error("failed to parse accounts metrics", "error", err)
 		return
 	}
+	pseudonyms, _ := ac.ctx.Value(types.LabelPseudonymsKey).(*LabelPseudonyms)
 	for a := range am {
+		account := pseudonyms.Account(a)
 		if am[a].pending > 0 {
-			ch <- prometheus.MustNewConstMetric(ac.pending, prometheus.GaugeValue, am[a].pending, a)
+			ch <- prometheus.MustNewConstMetric(ac.pending, prometheus.GaugeValue, am[a].pending, account)
 		}
 		if am[a].pending_cpus > 0 {
-			ch <- prometheus.MustNewConstMetric(ac.pending_cpus, prometheus.GaugeValue, am[a].pending_cpus, a)
+			ch <- prometheus.MustNewConstMetric(ac.pending_cpus, prometheus.GaugeValue, am[a].pending_cpus, account)
 		}
 		if am[a].running > 0 {
-			ch <- prometheus.MustNewConstMetric(ac.running, prometheus.GaugeValue, am[a].running, a)
+			ch <- prometheus.MustNewConstMetric(ac.running, prometheus.GaugeValue, am[a].running, account)
 		}
 		if am[a].running_cpus > 0 {
-			ch <- prometheus.MustNewConstMetric(ac.running_cpus, prometheus.GaugeValue, am[a].running_cpus, a)
+			ch <- prometheus.MustNewConstMetric(ac.running_cpus, prometheus.GaugeValue, am[a].running_cpus, account)
 		}
 		if am[a].suspended > 0 {
-			ch <- prometheus.MustNewConstMetric(ac.suspended, prometheus.GaugeValue, am[a].suspended, a)
+			ch <- prometheus.MustNewConstMetric(ac.suspended, prometheus.GaugeValue, am[a].suspended, account)
 		}
 	}
 }
@@ -95,11 +92,28 @@ func NewJobMetrics() *JobMetrics {
 	return &JobMetrics{}
 }
 
+// cpus is what accounts are ranked by for the top N
+func (m *JobMetrics) cpus() float64 {
+	return m.pending_cpus + m.running_cpus
+}
+
+func (m *JobMetrics) add(o *JobMetrics) {
+	m.pending += o.pending
+	m.pending_cpus += o.pending_cpus
+	m.running += o.running
+	m.running_cpus += o.running_cpus
+	m.suspended += o.suspended
+}
+
 // ParseAccountsMetrics gets the response body of jobs from SLURM and
-// parses it into a map of "accountName": *JobMetrics
-func ParseAccountsMetrics(jobsData api.JobsData) (map[string]*JobMetrics, error) {
+// parses it into a map of "accountName": *JobMetrics, limited by the cardinality
+func ParseAccountsMetrics(jobsData api.JobsData, c *Cardinality) (map[string]*JobMetrics, error) {
+	filter, top := c.accounts()
 	accounts := make(map[string]*JobMetrics)
 	for _, j := range jobsData.Jobs {
+		if !filter.Keep(j.Account) {
+			continue
+		}
 		// build the map with the account name as the key and job metrics as the value
 		_, key := accounts[j.Account]
 		if !key {
@@ -119,5 +133,6 @@ func ParseAccountsMetrics(jobsData api.JobsData) (map[string]*JobMetrics, error)
 			accounts[j.Account].suspended++
 		}
 	}
+	topLabels(accounts, top, (*JobMetrics).cpus, (*JobMetrics).add)
 	return accounts, nil
 }
@@ -1,5 +1,3 @@
-//go:build 2405
-
 package slurm
 
 import (
@@ -9,13 +7,13 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParseAccountsMetrics(t *testing.T) {
+func TestParseAccountsMetrics2405(t *testing.T) {
 	fb := util.ReadTestDataBytes("V0041OpenapiJobInfoResp.json")
-	jobsData, err := api.ProcessJobsResponse(fb)
+	jobsData, err := api.ProcessJobsResponse(api.V0041, fb)
 	if err != nil {
 		t.Fatalf("failed to process jobs data for accounts metrics: %v", err)
 	}
-	data, err := ParseAccountsMetrics(*jobsData)
+	data, err := ParseAccountsMetrics(*jobsData, nil)
 	if err != nil {
 		t.Fatalf("failed to parse accounts metrics: %v", err)
 	}
@@ -0,0 +1,109 @@
+package slurm
+
+import (
+	"regexp"
+	"sort"
+)
+
+// OtherLabel is the label value the users or accounts beyond the top N are
+// folded into
+const OtherLabel = "__other__"
+
+// LabelFilter decides which values of a label get their own series. A value
+// must match Include, if it is set, and must not match Exclude, if it is set.
+type LabelFilter struct {
+	Include *regexp.Regexp
+	Exclude *regexp.Regexp
+}
+
+// Keep reports whether series with the label value are exported
+func (f LabelFilter) Keep(value string) bool {
+	if f.Include != nil && !f.Include.MatchString(value) {
+		return false
+	}
+	if f.Exclude != nil && f.Exclude.MatchString(value) {
+		return false
+	}
+	return true
+}
+
+// Cardinality limits the number of series of the per-user, per-account,
+// per-partition and per-node metrics. Values dropped by a filter get no
+// series at all. With a top N, only the N users or accounts with the most
+// cpus in running and pending jobs keep their own series, and the rest are
+// added up into OtherLabel.
+//
+// A nil Cardinality keeps every series.
+type Cardinality struct {
+	Users      LabelFilter
+	Accounts   LabelFilter
+	Partitions LabelFilter
+	Nodes      LabelFilter
+
+	// TopUsers and TopAccounts are off when 0
+	TopUsers    int
+	TopAccounts int
+}
+
+func (c *Cardinality) users() (LabelFilter, int) {
+	if c == nil {
+		return LabelFilter{}, 0
+	}
+	return c.Users, c.TopUsers
+}
+
+func (c *Cardinality) accounts() (LabelFilter, int) {
+	if c == nil {
+		return LabelFilter{}, 0
+	}
+	return c.Accounts, c.TopAccounts
+}
+
+func (c *Cardinality) partitions() LabelFilter {
+	if c == nil {
+		return LabelFilter{}
+	}
+	return c.Partitions
+}
+
+func (c *Cardinality) nodes() LabelFilter {
+	if c == nil {
+		return LabelFilter{}
+	}
+	return c.Nodes
+}
+
+// filterLabels drops the entries of m whose names the filter doesn't keep
+func filterLabels[T any](m map[string]*T, f LabelFilter) {
+	for name := range m {
+		if !f.Keep(name) {
+			delete(m, name)
+		}
+	}
+}
+
+// topLabels keeps the n entries of m with the most cpus, and adds the others
+// up into an OtherLabel entry. Ties are broken by name, so the same entries
+// are kept on every scrape.
+func topLabels[T any](m map[string]*T, n int, cpus func(*T) float64, add func(to, from *T)) {
+	if n <= 0 || len(m) <= n {
+		return
+	}
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ci, cj := cpus(m[names[i]]), cpus(m[names[j]])
+		if ci != cj {
+			return ci > cj
+		}
+		return names[i] < names[j]
+	})
+	other := new(T)
+	for _, name := range names[n:] {
+		add(other, m[name])
+		delete(m, name)
+	}
+	m[OtherLabel] = other
+}
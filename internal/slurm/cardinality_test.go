@@ -0,0 +1,99 @@
+package slurm
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+)
+
+func TestLabelFilter(t *testing.T) {
+	f := LabelFilter{Include: regexp.MustCompile("^n.*$"), Exclude: regexp.MustCompile("^n01.*$")}
+	for value, keep := range map[string]bool{"n0201": true, "n0101": false, "gpu01": false} {
+		if f.Keep(value) != keep {
+			t.Errorf("expected Keep(%q) to be %v", value, keep)
+		}
+	}
+	if !(LabelFilter{}).Keep("anything") {
+		t.Errorf("expected an empty filter to keep everything")
+	}
+}
+
+func TestParseUsersMetricsTopUsers(t *testing.T) {
+	jobsData := &api.JobsData{Jobs: []api.JobData{
+		{UserName: "alice", Account: "physics", JobState: types.JobStateRunning, Cpus: 64},
+		{UserName: "bob", Account: "physics", JobState: types.JobStatePending, Cpus: 32},
+		{UserName: "carol", Account: "biology", JobState: types.JobStateRunning, Cpus: 8},
+		{UserName: "dave", Account: "biology", JobState: types.JobStateRunning, Cpus: 4},
+		{UserName: "dave", Account: "biology", JobState: types.JobStateSuspended, Cpus: 4},
+		{UserName: "svc-backup", Account: "ops", JobState: types.JobStateRunning, Cpus: 128},
+	}}
+	c := &Cardinality{
+		Users:    LabelFilter{Exclude: regexp.MustCompile("^svc-.*$")},
+		TopUsers: 2,
+	}
+	users, err := ParseUsersMetrics(jobsData, c)
+	if err != nil {
+		t.Fatalf("failed to parse users metrics: %v", err)
+	}
+	if len(users) != 3 || users["alice"] == nil || users["bob"] == nil {
+		t.Fatalf("expected alice, bob and %s, got %v", OtherLabel, users)
+	}
+	other := users[OtherLabel]
+	if other == nil || other.running != 2 || other.running_cpus != 12 || other.suspended != 1 {
+		t.Fatalf("expected carol and dave to be folded into %s, got %+v", OtherLabel, other)
+	}
+
+	c = &Cardinality{TopAccounts: 1}
+	accounts, err := ParseAccountsMetrics(*jobsData, c)
+	if err != nil {
+		t.Fatalf("failed to parse accounts metrics: %v", err)
+	}
+	if len(accounts) != 2 || accounts["ops"] == nil || accounts[OtherLabel].running_cpus != 76 || accounts[OtherLabel].pending_cpus != 32 {
+		t.Fatalf("expected ops and %s, got %v", OtherLabel, accounts)
+	}
+}
+
+func TestParseNodeMetricsFilter(t *testing.T) {
+	nodesData, err := api.ProcessNodesResponse(api.V0042, util.ReadTestDataBytes("V0042OpenapiNodesResp.json"))
+	if err != nil {
+		t.Fatalf("failed to decode nodes response: %v", err)
+	}
+	all, err := ParseNodeMetrics(nodesData, nil)
+	if err != nil {
+		t.Fatalf("failed to parse nodes metrics: %v", err)
+	}
+	c := &Cardinality{Nodes: LabelFilter{Include: regexp.MustCompile("^n016.*$")}}
+	some, err := ParseNodeMetrics(nodesData, c)
+	if err != nil {
+		t.Fatalf("failed to parse nodes metrics: %v", err)
+	}
+	if len(some) == 0 || len(some) >= len(all) || some["n0162"] == nil {
+		t.Fatalf("expected only the n016x nodes, got %d of %d", len(some), len(all))
+	}
+}
+
+func TestParseFairShareMetricsFilter(t *testing.T) {
+	sharesData, err := api.ProcessSharesResponse(api.V0042, util.ReadTestDataBytes("V0042OpenapiSharesResp.json"))
+	if err != nil {
+		t.Fatalf("failed to decode shares response: %v", err)
+	}
+	all, err := ParseFairShareMetrics(sharesData, nil)
+	if err != nil {
+		t.Fatalf("failed to parse fair share metrics: %v", err)
+	}
+	var name string
+	for name = range all {
+		break
+	}
+	c := &Cardinality{Accounts: LabelFilter{Exclude: regexp.MustCompile("^" + regexp.QuoteMeta(name) + "$")}, TopAccounts: 1}
+	some, err := ParseFairShareMetrics(sharesData, c)
+	if err != nil {
+		t.Fatalf("failed to parse fair share metrics: %v", err)
+	}
+	if _, found := some[name]; found || len(some) != len(all)-1 {
+		t.Fatalf("expected only %s to be left out, got %d of %d", name, len(some), len(all))
+	}
+}
@@ -0,0 +1,46 @@
+package slurm
+
+import (
+	"context"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector describes one of the collectors that can be turned on or off, and
+// the slurmrestd endpoints it reads
+type Collector struct {
+	Name      string
+	Endpoints []string
+	New       func(context.Context) prometheus.Collector
+}
+
+// Collectors lists every collector of the exporter
+var Collectors = []Collector{
+	{"accounts", []string{"jobs"}, func(ctx context.Context) prometheus.Collector { return NewAccountsCollector(ctx) }},
+	{"cpus", []string{"jobs", "nodes"}, func(ctx context.Context) prometheus.Collector { return NewCPUsCollector(ctx) }},
+	{"gpus", []string{"nodes"}, func(ctx context.Context) prometheus.Collector { return NewGPUsCollector(ctx) }},
+	{"nodes", []string{"nodes"}, func(ctx context.Context) prometheus.Collector { return NewNodesCollector(ctx) }},
+	{"node", []string{"nodes"}, func(ctx context.Context) prometheus.Collector { return NewNodeCollector(ctx) }},
+	{"partitions", []string{"partitions", "jobs", "nodes"}, func(ctx context.Context) prometheus.Collector { return NewPartitionsCollector(ctx) }},
+	{"fairshare", []string{"shares"}, func(ctx context.Context) prometheus.Collector { return NewFairShareCollector(ctx) }},
+	{"queue", []string{"jobs"}, func(ctx context.Context) prometheus.Collector { return NewQueueCollector(ctx) }},
+	{"scheduler", []string{"diag"}, func(ctx context.Context) prometheus.Collector { return NewSchedulerCollector(ctx) }},
+	{"users", []string{"jobs"}, func(ctx context.Context) prometheus.Collector { return NewUsersCollector(ctx) }},
+}
+
+// Endpoints returns the names of the endpoints the collectors read, sorted
+func Endpoints(collectors []Collector) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, c := range collectors {
+		for _, e := range c.Endpoints {
+			if !seen[e] {
+				seen[e] = true
+				names = append(names, e)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,23 @@
+package slurm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEndpoints(t *testing.T) {
+	if got := strings.Join(Endpoints(Collectors), ","); got != "diag,jobs,nodes,partitions,shares" {
+		t.Fatalf("expected every endpoint for all collectors, got %s", got)
+	}
+
+	// without fairshare nothing reads the shares endpoint
+	var enabled []Collector
+	for _, c := range Collectors {
+		if c.Name != "fairshare" {
+			enabled = append(enabled, c)
+		}
+	}
+	if got := strings.Join(Endpoints(enabled), ","); got != "diag,jobs,nodes,partitions" {
+		t.Fatalf("expected no shares endpoint without fairshare, got %s", got)
+	}
+}
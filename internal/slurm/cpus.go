@@ -4,7 +4,6 @@ import (
 	"context"
 	"log/slog"
 
-	"github.com/akyoto/cache"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
@@ -38,23 +37,13 @@ func (cc *CPUsCollector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (cc *CPUsCollector) Collect(ch chan<- prometheus.Metric) {
-	apiCache := cc.ctx.Value(types.ApiCacheKey).(*cache.Cache)
-	jobsRespBytes, found := apiCache.Get("jobs")
-	if !found {
-		slog.Error("failed to get jobs response for users metrics from cache")
-		return
-	}
-	jobsData, err := api.ProcessJobsResponse(jobsRespBytes.([]byte))
+	snapshot := cc.ctx.Value(types.ApiSnapshotKey).(*api.Snapshot)
+	jobsData, err := snapshot.Jobs()
 	if err != nil {
 		slog.Error("failed to process jobs response for cpu metrics", "error", err)
 		return
 	}
-	nodesRespBytes, found := apiCache.Get("nodes")
-	if !found {
-		slog.Error("failed to get nodes response for cpu metrics from cache")
-		return
-	}
-	nodesData, err := api.ProcessNodesResponse(nodesRespBytes.([]byte))
+	nodesData, err := snapshot.Nodes()
 	if err != nil {
 		slog.Error("failed to process nodes response for cpu metrics", "error", err)
 		return
@@ -1,5 +1,3 @@
-//go:build 2311
-
 package slurm
 
 import (
@@ -9,17 +7,23 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParseCPUsMetrics(t *testing.T) {
-	jobsBytes := util.ReadTestDataBytes("V0041OpenapiJobInfoResp.json")
-	nodesBytes := util.ReadTestDataBytes("V0041OpenapiNodesResp.json")
-	jobsData, _ := api.ProcessJobsResponse(jobsBytes)
-	nodesData, _ := api.ProcessNodesResponse(nodesBytes)
+func TestParseCPUsMetrics2311(t *testing.T) {
+	jobsBytes := util.ReadTestDataBytes("V0040OpenapiJobInfoResp.json")
+	nodesBytes := util.ReadTestDataBytes("V0040OpenapiNodesResp.json")
+	jobsData, err := api.ProcessJobsResponse(api.V0040, jobsBytes)
+	if err != nil {
+		t.Fatalf("failed to decode jobs response: %v", err)
+	}
+	nodesData, err := api.ProcessNodesResponse(api.V0040, nodesBytes)
+	if err != nil {
+		t.Fatalf("failed to decode nodes response: %v", err)
+	}
 	data, err := ParseCPUsMetrics(nodesData, jobsData)
 	if err != nil {
 		t.Fatalf("failed to parse cpu metrics: %v", err)
 	}
 	tt := []cpusMetrics{
-		{0, 0, 18, 18},
+		{1, 134, 361, 496},
 	}
 	for _, tc := range tt {
 		if data.alloc != tc.alloc {
@@ -1,5 +1,3 @@
-//go:build 2405
-
 package slurm
 
 import (
@@ -9,11 +7,17 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParseCPUsMetrics(t *testing.T) {
+func TestParseCPUsMetrics2405(t *testing.T) {
 	jobsBytes := util.ReadTestDataBytes("V0041OpenapiJobInfoResp.json")
 	nodesBytes := util.ReadTestDataBytes("V0041OpenapiNodesResp.json")
-	jobsData, _ := api.ProcessJobsResponse(jobsBytes)
-	nodesData, _ := api.ProcessNodesResponse(nodesBytes)
+	jobsData, err := api.ProcessJobsResponse(api.V0041, jobsBytes)
+	if err != nil {
+		t.Fatalf("failed to decode jobs response: %v", err)
+	}
+	nodesData, err := api.ProcessNodesResponse(api.V0041, nodesBytes)
+	if err != nil {
+		t.Fatalf("failed to decode nodes response: %v", err)
+	}
 	data, err := ParseCPUsMetrics(nodesData, jobsData)
 	if err != nil {
 		t.Fatalf("failed to parse cpu metrics: %v", err)
@@ -0,0 +1,42 @@
+package slurm
+
+import (
+	"testing"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+)
+
+func TestParseCPUsMetrics2411(t *testing.T) {
+	jobsBytes := util.ReadTestDataBytes("V0042OpenapiJobInfoResp.json")
+	nodesBytes := util.ReadTestDataBytes("V0042OpenapiNodesResp.json")
+	jobsData, err := api.ProcessJobsResponse(api.V0042, jobsBytes)
+	if err != nil {
+		t.Fatalf("failed to decode jobs response: %v", err)
+	}
+	nodesData, err := api.ProcessNodesResponse(api.V0042, nodesBytes)
+	if err != nil {
+		t.Fatalf("failed to decode nodes response: %v", err)
+	}
+	data, err := ParseCPUsMetrics(nodesData, jobsData)
+	if err != nil {
+		t.Fatalf("failed to parse cpu metrics: %v", err)
+	}
+	tt := []cpusMetrics{
+		{1, 134, 361, 496},
+	}
+	for _, tc := range tt {
+		if data.alloc != tc.alloc {
+			t.Fatalf("expected %v, got %v", tc.alloc, data.alloc)
+		}
+		if data.idle != tc.idle {
+			t.Fatalf("expected %v, got %v", tc.idle, data.idle)
+		}
+		if data.other != tc.other {
+			t.Fatalf("expected %v, got %v", tc.other, data.other)
+		}
+		if data.total != tc.total {
+			t.Fatalf("expected %v, got %v", tc.total, data.total)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"log/slog"
 
-	"github.com/akyoto/cache"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
@@ -28,25 +27,22 @@ func (fsc *FairShareCollector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (fsc *FairShareCollector) Collect(ch chan<- prometheus.Metric) {
-	apiCache := fsc.ctx.Value(types.ApiCacheKey).(*cache.Cache)
-	sharesRespBytes, found := apiCache.Get("shares")
-	if !found {
-		slog.Error("failed to get shares response for fair share metrics from cache")
-		return
-	}
+	snapshot := fsc.ctx.Value(types.ApiSnapshotKey).(*api.Snapshot)
 
-	sharesData, err := api.ProcessSharesResponse(sharesRespBytes.([]byte))
+	sharesData, err := snapshot.Shares()
 	if err != nil {
 		slog.Error("failed to process shares response for fair share metrics", "error", err)
 		return
 	}
-	fsm, err := ParseFairShareMetrics(sharesData)
+	cardinality, _ := fsc.ctx.Value(types.CardinalityKey).(*Cardinality)
+	fsm, err := ParseFairShareMetrics(sharesData, cardinality)
 	if err != nil {
 		slog.Error("failed to collect fair share metrics", "error", err)
 		return
 	}
+	pseudonyms, _ := fsc.ctx.Value(types.LabelPseudonymsKey).(*LabelPseudonyms)
 	for f := range fsm {
-		ch <- prometheus.MustNewConstMetric(fsc.fairshare, prometheus.GaugeValue, fsm[f].fairshare, f)
+		ch <- prometheus.MustNewConstMetric(fsc.fairshare, prometheus.GaugeValue, fsm[f].fairshare, pseudonyms.Account(f))
 	}
 }
 
@@ -58,7 +54,11 @@ func NewFairShareMetrics() *fairShareMetrics {
 	return &fairShareMetrics{}
 }
 
-func ParseFairShareMetrics(sharesData *api.SharesData) (map[string]*fairShareMetrics, error) {
+// ParseFairShareMetrics returns the fair share of each account, limited by the
+// account filter of the cardinality. Fair shares can't be added up, so there
+// is no top N.
+func ParseFairShareMetrics(sharesData *api.SharesData, c *Cardinality) (map[string]*fairShareMetrics, error) {
+	filter, _ := c.accounts()
 	accounts := make(map[string]*fairShareMetrics)
 	for _, s := range sharesData.Shares {
 		account := s.Name
@@ -66,6 +66,9 @@ func ParseFairShareMetrics(sharesData *api.SharesData) (map[string]*fairShareMet
 			// we don't care about the root account
 			continue
 		}
+		if !filter.Keep(account) {
+			continue
+		}
 		if _, exists := accounts[account]; !exists {
 			accounts[account] = NewFairShareMetrics()
 		}
@@ -1,5 +1,3 @@
-//go:build 2311
-
 package slurm
 
 import (
@@ -9,19 +7,27 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParseSharesMetrics(t *testing.T) {
-	sharesBytes := util.ReadTestDataBytes("SlurmV0041GetShares200Response.json")
-	sharesData, _ := api.ProcessSharesResponse(sharesBytes)
-	data, err := ParseFairShareMetrics(sharesData)
+func TestParseSharesMetrics2311(t *testing.T) {
+	sharesBytes := util.ReadTestDataBytes("V0040OpenapiSharesResp.json")
+	sharesData, err := api.ProcessSharesResponse(api.V0040, sharesBytes)
+	if err != nil {
+		t.Fatalf("failed to decode shares response: %v", err)
+	}
+	data, err := ParseFairShareMetrics(sharesData, nil)
 	if err != nil {
 		t.Fatalf("failed to parse fair share metrics: %v", err)
 	}
-	tt := []fairShareMetrics{
-		{2.3021358869347655},
+	tt := []struct {
+		name    string
+		metrics fairShareMetrics
+	}{
+		{"name", fairShareMetrics{2.3021358869347655}},
+		// an unset effective usage
+		{"user1", fairShareMetrics{0}},
 	}
 	for _, tc := range tt {
-		if data["name"].fairshare != tc.fairshare {
-			t.Fatalf("expected %v, got %v", tc.fairshare, data["name"].fairshare)
+		if data[tc.name].fairshare != tc.metrics.fairshare {
+			t.Fatalf("expected %v, got %v", tc.metrics.fairshare, data[tc.name].fairshare)
 		}
 	}
 }
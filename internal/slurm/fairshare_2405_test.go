@@ -1,5 +1,3 @@
-//go:build 2405
-
 package slurm
 
 import (
@@ -9,10 +7,13 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParseSharesMetrics(t *testing.T) {
-	sharesBytes := util.ReadTestDataBytes("SlurmV0041GetShares200Response.json")
-	sharesData, _ := api.ProcessSharesResponse(sharesBytes)
-	data, err := ParseFairShareMetrics(sharesData)
+func TestParseSharesMetrics2405(t *testing.T) {
+	sharesBytes := util.ReadTestDataBytes("V0041OpenapiSharesResp.json")
+	sharesData, err := api.ProcessSharesResponse(api.V0041, sharesBytes)
+	if err != nil {
+		t.Fatalf("failed to decode shares response: %v", err)
+	}
+	data, err := ParseFairShareMetrics(sharesData, nil)
 	if err != nil {
 		t.Fatalf("failed to parse fair share metrics: %v", err)
 	}
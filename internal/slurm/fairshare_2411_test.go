@@ -0,0 +1,36 @@
+package slurm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+)
+
+func TestParseSharesMetrics2411(t *testing.T) {
+	sharesBytes := util.ReadTestDataBytes("V0042OpenapiSharesResp.json")
+	sharesData, err := api.ProcessSharesResponse(api.V0042, sharesBytes)
+	if err != nil {
+		t.Fatalf("failed to decode shares response: %v", err)
+	}
+	data, err := ParseFairShareMetrics(sharesData, nil)
+	if err != nil {
+		t.Fatalf("failed to parse fair share metrics: %v", err)
+	}
+	tt := []struct {
+		name    string
+		metrics fairShareMetrics
+	}{
+		{"jamming", fairShareMetrics{0.657937}},
+		{"rdennis", fairShareMetrics{1}},
+		// infinite and unset numbers
+		{"idle", fairShareMetrics{math.MaxFloat64}},
+		{"nobody", fairShareMetrics{0}},
+	}
+	for _, tc := range tt {
+		if data[tc.name].fairshare != tc.metrics.fairshare {
+			t.Fatalf("expected %v, got %v", tc.metrics.fairshare, data[tc.name].fairshare)
+		}
+	}
+}
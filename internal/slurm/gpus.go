@@ -4,7 +4,6 @@ import (
 	"context"
 	"log/slog"
 
-	"github.com/akyoto/cache"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
@@ -38,13 +37,8 @@ func (cc *GPUsCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- cc.utilization
 }
 func (cc *GPUsCollector) Collect(ch chan<- prometheus.Metric) {
-	apiCache := cc.ctx.Value(types.ApiCacheKey).(*cache.Cache)
-	nodesRespBytes, found := apiCache.Get("nodes")
-	if !found {
-		slog.Error("failed to get nodes response for cpu metrics from cache")
-		return
-	}
-	nodesData, err := api.ProcessNodesResponse(nodesRespBytes.([]byte))
+	snapshot := cc.ctx.Value(types.ApiSnapshotKey).(*api.Snapshot)
+	nodesData, err := snapshot.Nodes()
 	if err != nil {
 		slog.Error("failed to process nodes response for gpu metrics", "error", err)
 		return
@@ -1,5 +1,3 @@
-//go:build 2311
-
 package slurm
 
 import (
@@ -9,15 +7,18 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParseGPUsMetrics(t *testing.T) {
-	nodesBytes := util.ReadTestDataBytes("V0041OpenapiNodesResp.json")
-	nodesData, _ := api.ProcessNodesResponse(nodesBytes)
+func TestParseGPUsMetrics2311(t *testing.T) {
+	nodesBytes := util.ReadTestDataBytes("V0040OpenapiNodesResp.json")
+	nodesData, err := api.ProcessNodesResponse(api.V0040, nodesBytes)
+	if err != nil {
+		t.Fatalf("failed to decode nodes response: %v", err)
+	}
 	data, err := ParseGPUsMetrics(nodesData)
 	if err != nil {
 		t.Fatalf("failed to parse gpu metrics: %v", err)
 	}
 	tt := []gpusMetrics{
-		{0, 0, 0, 0, 0},
+		{16, 63, 0, 79, 0},
 	}
 	for _, tc := range tt {
 		if data.alloc != tc.alloc {
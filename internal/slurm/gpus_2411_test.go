@@ -0,0 +1,40 @@
+package slurm
+
+import (
+	"testing"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+)
+
+func TestParseGPUsMetrics2411(t *testing.T) {
+	nodesBytes := util.ReadTestDataBytes("V0042OpenapiNodesResp.json")
+	nodesData, err := api.ProcessNodesResponse(api.V0042, nodesBytes)
+	if err != nil {
+		t.Fatalf("failed to decode nodes response: %v", err)
+	}
+	data, err := ParseGPUsMetrics(nodesData)
+	if err != nil {
+		t.Fatalf("failed to parse gpu metrics: %v", err)
+	}
+	tt := []gpusMetrics{
+		{16, 63, 0, 79, 0},
+	}
+	for _, tc := range tt {
+		if data.alloc != tc.alloc {
+			t.Fatalf("expected %v, got %v", tc.alloc, data.alloc)
+		}
+		if data.idle != tc.idle {
+			t.Fatalf("expected %v, got %v", tc.idle, data.idle)
+		}
+		if data.other != tc.other {
+			t.Fatalf("expected %v, got %v", tc.other, data.other)
+		}
+		if data.total != tc.total {
+			t.Fatalf("expected %v, got %v", tc.total, data.total)
+		}
+		if data.utilization != tc.utilization {
+			t.Fatalf("expected %v, got %v", tc.utilization, data.utilization)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log/slog"
 
-	"github.com/akyoto/cache"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
@@ -48,18 +47,14 @@ func (nc *NodeCollector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (nc *NodeCollector) Collect(ch chan<- prometheus.Metric) {
-	apiCache := nc.ctx.Value(types.ApiCacheKey).(*cache.Cache)
-	nodesRespBytes, found := apiCache.Get("nodes")
-	if !found {
-		slog.Error("failed to get nodes response for cpu metrics from cache")
-		return
-	}
-	nodesData, err := api.ProcessNodesResponse(nodesRespBytes.([]byte))
+	snapshot := nc.ctx.Value(types.ApiSnapshotKey).(*api.Snapshot)
+	nodesData, err := snapshot.Nodes()
 	if err != nil {
 		slog.Error("failed to process nodes response for node metrics", "error", err)
 		return
 	}
-	nm, err := ParseNodeMetrics(nodesData)
+	cardinality, _ := nc.ctx.Value(types.CardinalityKey).(*Cardinality)
+	nm, err := ParseNodeMetrics(nodesData, cardinality)
 	if err != nil {
 		slog.Error("failed to collect nodes metrics", "error", err)
 		return
@@ -90,12 +85,16 @@ func NewNodeMetrics() *nodeMetrics {
 }
 
 // ParseNodeMetrics takes the output of sinfo with node data
-// It returns a map of metrics per node
-func ParseNodeMetrics(nodesData *api.NodesData) (map[string]*nodeMetrics, error) {
+// It returns a map of metrics per node, limited by the cardinality
+func ParseNodeMetrics(nodesData *api.NodesData, c *Cardinality) (map[string]*nodeMetrics, error) {
+	filter := c.nodes()
 	nodeMap := make(map[string]*nodeMetrics)
 
 	for _, n := range nodesData.Nodes {
 		nodeName := n.Hostname
+		if !filter.Keep(nodeName) {
+			continue
+		}
 		nodeMap[nodeName] = &nodeMetrics{0, 0, 0, 0, 0, 0, ""}
 
 		// state
@@ -1,5 +1,3 @@
-//go:build 2311
-
 package slurm
 
 import (
@@ -9,34 +7,37 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParseNodeMetrics(t *testing.T) {
-	nodesBytes := util.ReadTestDataBytes("V0041OpenapiNodesResp.json")
-	nodesData, _ := api.ProcessNodesResponse(nodesBytes)
-	data, err := ParseNodeMetrics(nodesData)
+func TestParseNodeMetrics2311(t *testing.T) {
+	nodesBytes := util.ReadTestDataBytes("V0040OpenapiNodesResp.json")
+	nodesData, err := api.ProcessNodesResponse(api.V0040, nodesBytes)
+	if err != nil {
+		t.Fatalf("failed to decode nodes response: %v", err)
+	}
+	data, err := ParseNodeMetrics(nodesData, nil)
 	if err != nil {
 		t.Fatalf("failed to parse nodes metrics: %v", err)
 	}
 	tt := []nodeMetrics{
-		{6, 4, 8, 9, 0, 9, "invalid|invalid"},
+		{501632, 504433, 26, 22, 0, 48, "mix"},
 	}
 	for _, tc := range tt {
-		if data["hostname"].memAlloc != tc.memAlloc {
-			t.Fatalf("expected %v, got %v", tc.memAlloc, data["hostname"].memAlloc)
+		if data["n0162"].memAlloc != tc.memAlloc {
+			t.Fatalf("expected %v, got %v", tc.memAlloc, data["n0162"].memAlloc)
 		}
-		if data["hostname"].memTotal != tc.memTotal {
-			t.Fatalf("expected %v, got %v", tc.memTotal, data["hostname"].memTotal)
+		if data["n0162"].memTotal != tc.memTotal {
+			t.Fatalf("expected %v, got %v", tc.memTotal, data["n0162"].memTotal)
 		}
-		if data["hostname"].cpuAlloc != tc.cpuAlloc {
-			t.Fatalf("expected %v, got %v", tc.cpuAlloc, data["hostname"].cpuAlloc)
+		if data["n0162"].cpuAlloc != tc.cpuAlloc {
+			t.Fatalf("expected %v, got %v", tc.cpuAlloc, data["n0162"].cpuAlloc)
 		}
-		if data["hostname"].cpuIdle != tc.cpuIdle {
-			t.Fatalf("expected %v, got %v", tc.cpuIdle, data["hostname"].cpuIdle)
+		if data["n0162"].cpuIdle != tc.cpuIdle {
+			t.Fatalf("expected %v, got %v", tc.cpuIdle, data["n0162"].cpuIdle)
 		}
-		if data["hostname"].cpuOther != tc.cpuOther {
-			t.Fatalf("expected %v, got %v", tc.cpuOther, data["hostname"].cpuOther)
+		if data["n0162"].cpuOther != tc.cpuOther {
+			t.Fatalf("expected %v, got %v", tc.cpuOther, data["n0162"].cpuOther)
 		}
-		if data["hostname"].cpuTotal != tc.cpuTotal {
-			t.Fatalf("expected %v, got %v", tc.cpuTotal, data["hostname"].cpuTotal)
+		if data["n0162"].cpuTotal != tc.cpuTotal {
+			t.Fatalf("expected %v, got %v", tc.cpuTotal, data["n0162"].cpuTotal)
 		}
 	}
 }
@@ -1,5 +1,3 @@
-//go:build 2405
-
 package slurm
 
 import (
@@ -9,10 +7,13 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParseNodeMetrics(t *testing.T) {
+func TestParseNodeMetrics2405(t *testing.T) {
 	nodesBytes := util.ReadTestDataBytes("V0041OpenapiNodesResp.json")
-	nodesData, _ := api.ProcessNodesResponse(nodesBytes)
-	data, err := ParseNodeMetrics(nodesData)
+	nodesData, err := api.ProcessNodesResponse(api.V0041, nodesBytes)
+	if err != nil {
+		t.Fatalf("failed to decode nodes response: %v", err)
+	}
+	data, err := ParseNodeMetrics(nodesData, nil)
 	if err != nil {
 		t.Fatalf("failed to parse nodes metrics: %v", err)
 	}
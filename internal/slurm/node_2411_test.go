@@ -0,0 +1,43 @@
+package slurm
+
+import (
+	"testing"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+)
+
+func TestParseNodeMetrics2411(t *testing.T) {
+	nodesBytes := util.ReadTestDataBytes("V0042OpenapiNodesResp.json")
+	nodesData, err := api.ProcessNodesResponse(api.V0042, nodesBytes)
+	if err != nil {
+		t.Fatalf("failed to decode nodes response: %v", err)
+	}
+	data, err := ParseNodeMetrics(nodesData, nil)
+	if err != nil {
+		t.Fatalf("failed to parse nodes metrics: %v", err)
+	}
+	tt := []nodeMetrics{
+		{501632, 504433, 26, 22, 0, 48, "mix"},
+	}
+	for _, tc := range tt {
+		if data["n0162"].memAlloc != tc.memAlloc {
+			t.Fatalf("expected %v, got %v", tc.memAlloc, data["n0162"].memAlloc)
+		}
+		if data["n0162"].memTotal != tc.memTotal {
+			t.Fatalf("expected %v, got %v", tc.memTotal, data["n0162"].memTotal)
+		}
+		if data["n0162"].cpuAlloc != tc.cpuAlloc {
+			t.Fatalf("expected %v, got %v", tc.cpuAlloc, data["n0162"].cpuAlloc)
+		}
+		if data["n0162"].cpuIdle != tc.cpuIdle {
+			t.Fatalf("expected %v, got %v", tc.cpuIdle, data["n0162"].cpuIdle)
+		}
+		if data["n0162"].cpuOther != tc.cpuOther {
+			t.Fatalf("expected %v, got %v", tc.cpuOther, data["n0162"].cpuOther)
+		}
+		if data["n0162"].cpuTotal != tc.cpuTotal {
+			t.Fatalf("expected %v, got %v", tc.cpuTotal, data["n0162"].cpuTotal)
+		}
+	}
+}
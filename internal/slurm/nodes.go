@@ -4,7 +4,6 @@ import (
 	"context"
 	"log/slog"
 
-	"github.com/akyoto/cache"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
@@ -54,13 +53,8 @@ func (nc *NodesCollector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (nc *NodesCollector) Collect(ch chan<- prometheus.Metric) {
-	apiCache := nc.ctx.Value(types.ApiCacheKey).(*cache.Cache)
-	nodesRespBytes, found := apiCache.Get("nodes")
-	if !found {
-		slog.Error("failed to get nodes response for cpu metrics from cache")
-		return
-	}
-	nodesData, err := api.ProcessNodesResponse(nodesRespBytes.([]byte))
+	snapshot := nc.ctx.Value(types.ApiSnapshotKey).(*api.Snapshot)
+	nodesData, err := snapshot.Nodes()
 	if err != nil {
 		slog.Error("failed to process nodes response for nodes metrics", "error", err)
 		return
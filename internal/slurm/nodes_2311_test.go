@@ -1,5 +1,3 @@
-//go:build 2311
-
 package slurm
 
 import (
@@ -9,15 +7,18 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParseNodesMetrics(t *testing.T) {
-	nodesBytes := util.ReadTestDataBytes("V0041OpenapiNodesResp.json")
-	nodesData, _ := api.ProcessNodesResponse(nodesBytes)
+func TestParseNodesMetrics2311(t *testing.T) {
+	nodesBytes := util.ReadTestDataBytes("V0040OpenapiNodesResp.json")
+	nodesData, err := api.ProcessNodesResponse(api.V0040, nodesBytes)
+	if err != nil {
+		t.Fatalf("failed to decode nodes response: %v", err)
+	}
 	data, err := ParseNodesMetrics(nodesData)
 	if err != nil {
 		t.Fatalf("failed to parse nodes metrics: %v", err)
 	}
 	tt := []nodesMetrics{
-		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{6, 0, 2, 2, 0, 0, 2, 0, 2, 0},
 	}
 	for _, tc := range tt {
 		if data.alloc != tc.alloc {
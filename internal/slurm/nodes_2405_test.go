@@ -1,5 +1,3 @@
-//go:build 2405
-
 package slurm
 
 import (
@@ -9,9 +7,12 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParseNodesMetrics(t *testing.T) {
+func TestParseNodesMetrics2405(t *testing.T) {
 	nodesBytes := util.ReadTestDataBytes("V0041OpenapiNodesResp.json")
-	nodesData, _ := api.ProcessNodesResponse(nodesBytes)
+	nodesData, err := api.ProcessNodesResponse(api.V0041, nodesBytes)
+	if err != nil {
+		t.Fatalf("failed to decode nodes response: %v", err)
+	}
 	data, err := ParseNodesMetrics(nodesData)
 	if err != nil {
 		t.Fatalf("failed to parse nodes metrics: %v", err)
@@ -5,7 +5,6 @@ import (
 	"log/slog"
 	"strings"
 
-	"github.com/akyoto/cache"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
@@ -41,38 +40,24 @@ func (pc *PartitionsCollector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (pc *PartitionsCollector) Collect(ch chan<- prometheus.Metric) {
-	apiCache := pc.ctx.Value(types.ApiCacheKey).(*cache.Cache)
-	partitionsRespBytes, found := apiCache.Get("partitions")
-	if !found {
-		slog.Error("failed to get partitions response for partitions metrics from cache")
-		return
-	}
-	jobsRespBytes, found := apiCache.Get("jobs")
-	if !found {
-		slog.Error("failed to get jobs response for users metrics from cache")
-		return
-	}
-	nodesRespBytes, found := apiCache.Get("nodes")
-	if !found {
-		slog.Error("failed to get nodes response for cpu metrics from cache")
-		return
-	}
-	partitionsData, err := api.ProcessPartitionsResponse(partitionsRespBytes.([]byte))
+	snapshot := pc.ctx.Value(types.ApiSnapshotKey).(*api.Snapshot)
+	partitionsData, err := snapshot.Partitions()
 	if err != nil {
 		slog.Error("failed to process partitions data for partitions metrics", "error", err)
 		return
 	}
-	jobsData, err := api.ProcessJobsResponse(jobsRespBytes.([]byte))
+	jobsData, err := snapshot.Jobs()
 	if err != nil {
 		slog.Error("failed to process jobs data for partitions metrics", "error", err)
 		return
 	}
-	nodesData, err := api.ProcessNodesResponse(nodesRespBytes.([]byte))
+	nodesData, err := snapshot.Nodes()
 	if err != nil {
 		slog.Error("failed to process nodes data for partitions metrics", "error", err)
 		return
 	}
-	pm, err := ParsePartitionsMetrics(partitionsData, jobsData, nodesData)
+	cardinality, _ := pc.ctx.Value(types.CardinalityKey).(*Cardinality)
+	pm, err := ParsePartitionsMetrics(partitionsData, jobsData, nodesData, cardinality)
 	if err != nil {
 		slog.Error("failed to collect partitions metrics", "error", err)
 		return
@@ -108,8 +93,9 @@ type partitionMetrics struct {
 	jobs_pending   float64
 }
 
-// ParsePartitionsMetrics returns a map where the keys are the partition names and the values are a partitionMetrics struct
-func ParsePartitionsMetrics(partitionsData *api.PartitionsData, jobsData *api.JobsData, nodesData *api.NodesData) (map[string]*partitionMetrics, error) {
+// ParsePartitionsMetrics returns a map where the keys are the partition names and the values are a partitionMetrics struct,
+// limited by the partition filter of the cardinality
+func ParsePartitionsMetrics(partitionsData *api.PartitionsData, jobsData *api.JobsData, nodesData *api.NodesData, c *Cardinality) (map[string]*partitionMetrics, error) {
 	partitions := make(map[string]*partitionMetrics)
 	nodePartitions := make(map[string][]string)
 
@@ -175,5 +161,6 @@ func ParsePartitionsMetrics(partitionsData *api.PartitionsData, jobsData *api.Jo
 		}
 	}
 
+	filterLabels(partitions, c.partitions())
 	return partitions, nil
 }
@@ -1,5 +1,3 @@
-//go:build 2311
-
 package slurm
 
 import (
@@ -9,23 +7,23 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParsePartitionsMetrics(t *testing.T) {
-	nodesBytes := util.ReadTestDataBytes("V0041OpenapiNodesResp.json")
-	nodesData, err := api.ProcessNodesResponse(nodesBytes)
+func TestParsePartitionsMetrics2311(t *testing.T) {
+	nodesBytes := util.ReadTestDataBytes("V0040OpenapiNodesResp.json")
+	nodesData, err := api.ProcessNodesResponse(api.V0040, nodesBytes)
 	if err != nil {
 		t.Fatalf("failed to extract nodes response: %v", err)
 	}
-	jobsBytes := util.ReadTestDataBytes("V0041OpenapiJobInfoResp.json")
-	jobsData, _ := api.ProcessJobsResponse(jobsBytes)
+	jobsBytes := util.ReadTestDataBytes("V0040OpenapiJobInfoResp.json")
+	jobsData, err := api.ProcessJobsResponse(api.V0040, jobsBytes)
 	if err != nil {
 		t.Fatalf("failed to extract jobs response: %v", err)
 	}
-	partitionsBytes := util.ReadTestDataBytes("V0041OpenapiPartitionResp.json")
-	partitionData, _ := api.ProcessPartitionsResponse(partitionsBytes)
+	partitionsBytes := util.ReadTestDataBytes("V0040OpenapiPartitionResp.json")
+	partitionData, err := api.ProcessPartitionsResponse(api.V0040, partitionsBytes)
 	if err != nil {
 		t.Fatalf("failed to extract partitions response: %v", err)
 	}
-	data, err := ParsePartitionsMetrics(partitionData, jobsData, nodesData)
+	data, err := ParsePartitionsMetrics(partitionData, jobsData, nodesData, nil)
 	if err != nil {
 		t.Fatalf("failed to parse partitions metrics: %v", err)
 	}
@@ -33,9 +31,9 @@ func TestParsePartitionsMetrics(t *testing.T) {
 		name    string
 		metrics partitionMetrics
 	}{
-		{"name", partitionMetrics{0, 0, 1, 1, 0}},
-		{"partition", partitionMetrics{0, 0, 0, 0, 2}},
-		{"partitions", partitionMetrics{32, 36, -68, 0, 0}},
+		{"preempt", partitionMetrics{266, 118, 17388, 17772, 2}},
+		{"gpu", partitionMetrics{122, 70, 912, 1104, 0}},
+		{"compute", partitionMetrics{0, 0, 5376, 5376, 0}},
 	}
 	for _, tc := range tt {
 		if data[tc.name].cpus_allocated != tc.metrics.cpus_allocated {
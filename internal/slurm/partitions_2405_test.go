@@ -1,5 +1,3 @@
-//go:build 2405
-
 package slurm
 
 import (
@@ -9,23 +7,23 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParsePartitionsMetrics(t *testing.T) {
+func TestParsePartitionsMetrics2405(t *testing.T) {
 	nodesBytes := util.ReadTestDataBytes("V0041OpenapiNodesResp.json")
-	nodesData, err := api.ProcessNodesResponse(nodesBytes)
+	nodesData, err := api.ProcessNodesResponse(api.V0041, nodesBytes)
 	if err != nil {
 		t.Fatalf("failed to extract nodes response: %v", err)
 	}
 	jobsBytes := util.ReadTestDataBytes("V0041OpenapiJobInfoResp.json")
-	jobsData, _ := api.ProcessJobsResponse(jobsBytes)
+	jobsData, err := api.ProcessJobsResponse(api.V0041, jobsBytes)
 	if err != nil {
 		t.Fatalf("failed to extract jobs response: %v", err)
 	}
 	partitionsBytes := util.ReadTestDataBytes("V0041OpenapiPartitionResp.json")
-	partitionData, _ := api.ProcessPartitionsResponse(partitionsBytes)
+	partitionData, err := api.ProcessPartitionsResponse(api.V0041, partitionsBytes)
 	if err != nil {
 		t.Fatalf("failed to extract partitions response: %v", err)
 	}
-	data, err := ParsePartitionsMetrics(partitionData, jobsData, nodesData)
+	data, err := ParsePartitionsMetrics(partitionData, jobsData, nodesData, nil)
 	if err != nil {
 		t.Fatalf("failed to parse partitions metrics: %v", err)
 	}
@@ -0,0 +1,55 @@
+package slurm
+
+import (
+	"testing"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+)
+
+func TestParsePartitionsMetrics2411(t *testing.T) {
+	nodesBytes := util.ReadTestDataBytes("V0042OpenapiNodesResp.json")
+	nodesData, err := api.ProcessNodesResponse(api.V0042, nodesBytes)
+	if err != nil {
+		t.Fatalf("failed to extract nodes response: %v", err)
+	}
+	jobsBytes := util.ReadTestDataBytes("V0042OpenapiJobInfoResp.json")
+	jobsData, err := api.ProcessJobsResponse(api.V0042, jobsBytes)
+	if err != nil {
+		t.Fatalf("failed to extract jobs response: %v", err)
+	}
+	partitionsBytes := util.ReadTestDataBytes("V0042OpenapiPartitionResp.json")
+	partitionData, err := api.ProcessPartitionsResponse(api.V0042, partitionsBytes)
+	if err != nil {
+		t.Fatalf("failed to extract partitions response: %v", err)
+	}
+	data, err := ParsePartitionsMetrics(partitionData, jobsData, nodesData, nil)
+	if err != nil {
+		t.Fatalf("failed to parse partitions metrics: %v", err)
+	}
+	tt := []struct {
+		name    string
+		metrics partitionMetrics
+	}{
+		{"preempt", partitionMetrics{266, 118, 17388, 17772, 2}},
+		{"gpu", partitionMetrics{122, 70, 912, 1104, 0}},
+		{"compute", partitionMetrics{0, 0, 5376, 5376, 0}},
+	}
+	for _, tc := range tt {
+		if data[tc.name].cpus_allocated != tc.metrics.cpus_allocated {
+			t.Fatalf("expected %v, got %v", tc.metrics.cpus_allocated, data[tc.name].cpus_allocated)
+		}
+		if data[tc.name].cpus_idle != tc.metrics.cpus_idle {
+			t.Fatalf("expected %v, got %v", tc.metrics.cpus_idle, data[tc.name].cpus_idle)
+		}
+		if data[tc.name].cpus_other != tc.metrics.cpus_other {
+			t.Fatalf("expected %v, got %v", tc.metrics.cpus_other, data[tc.name].cpus_other)
+		}
+		if data[tc.name].cpus_total != tc.metrics.cpus_total {
+			t.Fatalf("expected %v, got %v", tc.metrics.cpus_total, data[tc.name].cpus_total)
+		}
+		if data[tc.name].jobs_pending != tc.metrics.jobs_pending {
+			t.Fatalf("expected %v, got %v", tc.metrics.jobs_pending, data[tc.name].jobs_pending)
+		}
+	}
+}
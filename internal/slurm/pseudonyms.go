@@ -0,0 +1,124 @@
+package slurm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// minPseudonymKeySize is the least number of bytes a pseudonym key must have
+const minPseudonymKeySize = 16
+
+// LabelPseudonyms replaces user and account label values with pseudonyms, so
+// the metrics can be shared with people who may not see who runs what. A
+// pseudonym is a keyed HMAC of the name, so it stays the same across restarts
+// and between exporters with the same key, and can't be traced back to the
+// name without the key. The names behind the pseudonyms handed out are kept,
+// so they can be looked up again.
+//
+// A nil LabelPseudonyms leaves the label values as they are.
+type LabelPseudonyms struct {
+	key []byte
+
+	mu sync.Mutex
+	// names maps the pseudonyms handed out to the names they stand for
+	names map[string]string
+}
+
+// NewLabelPseudonyms returns a LabelPseudonyms deriving pseudonyms with key
+func NewLabelPseudonyms(key []byte) *LabelPseudonyms {
+	return &LabelPseudonyms{key: key, names: make(map[string]string)}
+}
+
+// LoadLabelPseudonyms returns a LabelPseudonyms with the key in the file at
+// path. Relative paths are resolved against $CREDENTIALS_DIRECTORY when it is
+// set, like the token file.
+func LoadLabelPseudonyms(path string) (*LabelPseudonyms, error) {
+	credsDir, found := os.LookupEnv("CREDENTIALS_DIRECTORY")
+	if found && !filepath.IsAbs(path) {
+		path = filepath.Join(credsDir, path)
+	}
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pseudonym key: %v", err)
+	}
+	// keys written with echo end in a newline that isn't part of them
+	key = bytes.TrimSpace(key)
+	if len(key) < minPseudonymKeySize {
+		return nil, fmt.Errorf("pseudonym key in %s is too short, it must have at least %d bytes", path, minPseudonymKeySize)
+	}
+	return NewLabelPseudonyms(key), nil
+}
+
+// User returns the pseudonym of a user name, like "user-3f9a0c1d2e4b5a69"
+func (p *LabelPseudonyms) User(name string) string {
+	return p.pseudonym("user", name)
+}
+
+// Account returns the pseudonym of an account name, like "account-0b8e5d7c6a2f1e93"
+func (p *LabelPseudonyms) Account(name string) string {
+	return p.pseudonym("account", name)
+}
+
+// pseudonym derives the pseudonym of a name of the given kind. The kind is
+// part of the HMAC, so a user and an account of the same name get different
+// pseudonyms. The users or accounts folded together beyond the top N stand
+// for no one in particular, so they keep their label value.
+func (p *LabelPseudonyms) pseudonym(kind, name string) string {
+	if p == nil || name == OtherLabel {
+		return name
+	}
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(name))
+	pseudonym := kind + "-" + hex.EncodeToString(mac.Sum(nil))[:16]
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.names[pseudonym] = name
+	return pseudonym
+}
+
+// Lookup returns the name a pseudonym stands for. Only pseudonyms handed out
+// since the exporter started can be looked up.
+func (p *LabelPseudonyms) Lookup(pseudonym string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	name, found := p.names[pseudonym]
+	return name, found
+}
+
+// Handler looks up pseudonyms. GET /lookup?pseudonym=user-3f9a0c1d2e4b5a69
+// answers with the name, and GET /mapping with every pseudonym handed out and
+// its name as a JSON object. It must only be served where admins can reach it.
+func (p *LabelPseudonyms) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/lookup", func(w http.ResponseWriter, r *http.Request) {
+		name, found := p.Lookup(r.URL.Query().Get("pseudonym"))
+		if !found {
+			http.Error(w, "unknown pseudonym", http.StatusNotFound)
+			return
+		}
+		fmt.Fprintln(w, name)
+	})
+	mux.HandleFunc("/mapping", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		b, err := json.MarshalIndent(p.names, "", "  ")
+		p.mu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+	return mux
+}
@@ -0,0 +1,106 @@
+package slurm
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestLabelPseudonyms(t *testing.T) {
+	p := NewLabelPseudonyms([]byte("0123456789abcdef"))
+	user := p.User("rdennis")
+	if !strings.HasPrefix(user, "user-") || strings.Contains(user, "rdennis") {
+		t.Fatalf("unexpected pseudonym %s", user)
+	}
+	if again := NewLabelPseudonyms([]byte("0123456789abcdef")).User("rdennis"); again != user {
+		t.Fatalf("expected the same key to give the same pseudonym, got %s and %s", user, again)
+	}
+	if other := NewLabelPseudonyms([]byte("fedcba9876543210")).User("rdennis"); other == user {
+		t.Fatalf("expected another key to give another pseudonym")
+	}
+	if account := p.Account("rdennis"); account == user || !strings.HasPrefix(account, "account-") {
+		t.Fatalf("expected an account to get its own pseudonym, got %s", account)
+	}
+
+	if name, found := p.Lookup(user); !found || name != "rdennis" {
+		t.Fatalf("expected to look up rdennis, got %q", name)
+	}
+	w := httptest.NewRecorder()
+	p.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/lookup?pseudonym="+user, nil))
+	if w.Code != 200 || strings.TrimSpace(w.Body.String()) != "rdennis" {
+		t.Fatalf("unexpected lookup response %d %q", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	p.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/lookup?pseudonym=user-0000000000000000", nil))
+	if w.Code != 404 {
+		t.Fatalf("expected an unknown pseudonym to be not found, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	p.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/mapping", nil))
+	if !strings.Contains(w.Body.String(), `"`+user+`": "rdennis"`) {
+		t.Fatalf("expected the mapping to list %s, got %s", user, w.Body.String())
+	}
+
+	var none *LabelPseudonyms
+	if name := none.User("rdennis"); name != "rdennis" {
+		t.Fatalf("expected no pseudonyms to keep the name, got %s", name)
+	}
+}
+
+func TestLoadLabelPseudonyms(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "key")
+	if err := os.WriteFile(path, []byte("short\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLabelPseudonyms(path); err == nil {
+		t.Fatalf("expected a short key to be refused")
+	}
+	if err := os.WriteFile(path, []byte("0123456789abcdef\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadLabelPseudonyms(path)
+	if err != nil {
+		t.Fatalf("failed to load key: %v", err)
+	}
+	if p.User("rdennis") != NewLabelPseudonyms([]byte("0123456789abcdef")).User("rdennis") {
+		t.Fatalf("expected the trailing newline not to be part of the key")
+	}
+}
+
+func TestUsersCollectorPseudonyms(t *testing.T) {
+	p := NewLabelPseudonyms([]byte("0123456789abcdef"))
+	snapshot := api.NewSnapshot(api.V0042, time.Now(), map[string][]byte{
+		"jobs": util.ReadTestDataBytes("V0042OpenapiJobInfoResp.json"),
+	})
+	ctx := context.WithValue(context.Background(), types.ApiSnapshotKey, snapshot)
+	ctx = context.WithValue(ctx, types.LabelPseudonymsKey, p)
+
+	r := prometheus.NewPedanticRegistry()
+	r.MustRegister(NewUsersCollector(ctx), NewAccountsCollector(ctx))
+	mfs, err := r.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	if len(mfs) == 0 {
+		t.Fatalf("expected metrics")
+	}
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if _, found := p.Lookup(l.GetValue()); !found {
+					t.Errorf("expected a pseudonym in %s{%s}, got %q", mf.GetName(), l.GetName(), l.GetValue())
+				}
+			}
+		}
+	}
+}
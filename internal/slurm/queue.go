@@ -4,7 +4,6 @@ import (
 	"context"
 	"log/slog"
 
-	"github.com/akyoto/cache"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
@@ -60,13 +59,8 @@ func (qc *QueueCollector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (qc *QueueCollector) Collect(ch chan<- prometheus.Metric) {
-	apiCache := qc.ctx.Value(types.ApiCacheKey).(*cache.Cache)
-	jobsRespBytes, found := apiCache.Get("jobs")
-	if !found {
-		slog.Error("failed to get jobs response for users metrics from cache")
-		return
-	}
-	jobsData, err := api.ProcessJobsResponse(jobsRespBytes.([]byte))
+	snapshot := qc.ctx.Value(types.ApiSnapshotKey).(*api.Snapshot)
+	jobsData, err := snapshot.Jobs()
 	if err != nil {
 		slog.Error("failed to process jobs data for queue metrics", "error", err)
 		return
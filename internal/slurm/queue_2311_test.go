@@ -1,5 +1,3 @@
-//go:build 2311
-
 package slurm
 
 import (
@@ -9,15 +7,18 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParseQueueMetrics(t *testing.T) {
-	jobsBytes := util.ReadTestDataBytes("V0041OpenapiJobInfoResp.json")
-	jobsData, _ := api.ProcessJobsResponse(jobsBytes)
+func TestParseQueueMetrics2311(t *testing.T) {
+	jobsBytes := util.ReadTestDataBytes("V0040OpenapiJobInfoResp.json")
+	jobsData, err := api.ProcessJobsResponse(api.V0040, jobsBytes)
+	if err != nil {
+		t.Fatalf("failed to decode jobs response: %v", err)
+	}
 	data, err := ParseQueueMetrics(jobsData)
 	if err != nil {
 		t.Fatalf("failed to parse queue metrics: %v", err)
 	}
 	tt := []queueMetrics{
-		{0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{1, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0},
 	}
 	for _, tc := range tt {
 		if data.pending != tc.pending {
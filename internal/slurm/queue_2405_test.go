@@ -1,5 +1,3 @@
-//go:build 2405
-
 package slurm
 
 import (
@@ -9,9 +7,12 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParseQueueMetrics(t *testing.T) {
+func TestParseQueueMetrics2405(t *testing.T) {
 	jobsBytes := util.ReadTestDataBytes("V0041OpenapiJobInfoResp.json")
-	jobsData, _ := api.ProcessJobsResponse(jobsBytes)
+	jobsData, err := api.ProcessJobsResponse(api.V0041, jobsBytes)
+	if err != nil {
+		t.Fatalf("failed to decode jobs response: %v", err)
+	}
 	data, err := ParseQueueMetrics(jobsData)
 	if err != nil {
 		t.Fatalf("failed to parse queue metrics: %v", err)
@@ -0,0 +1,61 @@
+package slurm
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestReplayTestData serves testdata/ as recorded responses, like
+// --api.url=file://.../testdata does, and scrapes every collector with the
+// responses of each version
+func TestReplayTestData(t *testing.T) {
+	url := api.CleanseBaseURL("file://" + filepath.Dir(util.GetTestDataFilePath("x")))
+	for _, v := range []*api.Version{api.V0040, api.V0041, api.V0042} {
+		t.Run(v.Release, func(t *testing.T) {
+			ctx := context.Background()
+			ctx = context.WithValue(ctx, types.ApiURLKey, url)
+			ctx = context.WithValue(ctx, types.ApiClientKey, api.NewHTTPClient(url, api.ClientOptions{}))
+			ctx = context.WithValue(ctx, types.ApiEnabledEndpointsKey, Endpoints(Collectors))
+			ctx = api.RegisterEndpoints(ctx, v)
+
+			scraped := false
+			h := api.MetricsHandler(prometheus.NewRegistry(), ctx, func(ctx context.Context) []prometheus.Collector {
+				scraped = true
+				s := ctx.Value(types.ApiSnapshotKey).(*api.Snapshot)
+				if s.Errors != nil {
+					t.Fatalf("failed to replay testdata: %v", s.Errors)
+				}
+				decoders := map[string]func() error{
+					"jobs":       func() error { _, err := s.Jobs(); return err },
+					"nodes":      func() error { _, err := s.Nodes(); return err },
+					"partitions": func() error { _, err := s.Partitions(); return err },
+					"diag":       func() error { _, err := s.Diag(); return err },
+					"shares":     func() error { _, err := s.Shares(); return err },
+				}
+				for name, decode := range decoders {
+					if err := decode(); err != nil {
+						t.Fatalf("failed to decode the replayed %s response: %v", name, err)
+					}
+				}
+				for _, c := range Collectors {
+					if n := testutil.CollectAndCount(c.New(ctx)); n == 0 {
+						t.Errorf("expected metrics from the %s collector", c.Name)
+					}
+				}
+				return nil
+			})
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+			if !scraped {
+				t.Fatalf("expected the collectors to be built")
+			}
+		})
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"log/slog"
 
-	"github.com/akyoto/cache"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
@@ -110,13 +109,8 @@ func (c *SchedulerCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Send the values of all metrics
 func (sc *SchedulerCollector) Collect(ch chan<- prometheus.Metric) {
-	apiCache := sc.ctx.Value(types.ApiCacheKey).(*cache.Cache)
-	diagRespBytes, found := apiCache.Get("diag")
-	if !found {
-		slog.Error("failed to get diag response for scheduler metrics from cache")
-		return
-	}
-	diagData, err := api.ProcessDiagResponse(diagRespBytes.([]byte))
+	snapshot := sc.ctx.Value(types.ApiSnapshotKey).(*api.Snapshot)
+	diagData, err := snapshot.Diag()
 	if err != nil {
 		slog.Error("failed to process diag response for scheduler metrics", "error", err)
 		return
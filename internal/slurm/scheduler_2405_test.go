@@ -1,5 +1,3 @@
-//go:build 2405
-
 package slurm
 
 import (
@@ -9,9 +7,12 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParseSchedulerMetrics(t *testing.T) {
-	diagBytes := util.ReadTestDataBytes("SlurmV0041GetDiag200Response.json")
-	diagData, _ := api.ProcessDiagResponse(diagBytes)
+func TestParseSchedulerMetrics2405(t *testing.T) {
+	diagBytes := util.ReadTestDataBytes("V0041OpenapiDiagResp.json")
+	diagData, err := api.ProcessDiagResponse(api.V0041, diagBytes)
+	if err != nil {
+		t.Fatalf("failed to decode diag response: %v", err)
+	}
 	data, err := ParseSchedulerMetrics(diagData)
 	if err != nil {
 		t.Fatalf("failed to parse scheduler metrics: %v", err)
@@ -0,0 +1,121 @@
+package slurm
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
+	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the number of collectors that read the jobs and nodes responses
+const (
+	jobsCollectors  = 5
+	nodesCollectors = 5
+)
+
+// replicate repeats the records in the list of a response n times, to get
+// closer to the size of the responses of a busy cluster
+func replicate(b []byte, key string, n int) []byte {
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(b, &resp); err != nil {
+		panic(err)
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(resp[key], &list); err != nil {
+		panic(err)
+	}
+	var out []json.RawMessage
+	for i := 0; i < n; i++ {
+		out = append(out, list...)
+	}
+	resp[key], _ = json.Marshal(out)
+	b, _ = json.Marshal(resp)
+	return b
+}
+
+func benchmarkResponses() map[string][]byte {
+	return map[string][]byte{
+		"jobs":       replicate(util.ReadTestDataBytes("V0040OpenapiJobInfoResp.json"), "jobs", 500),
+		"nodes":      replicate(util.ReadTestDataBytes("V0040OpenapiNodesResp.json"), "nodes", 100),
+		"partitions": util.ReadTestDataBytes("V0040OpenapiPartitionResp.json"),
+		"diag":       util.ReadTestDataBytes("V0040OpenapiDiagResp.json"),
+		"shares":     util.ReadTestDataBytes("V0040OpenapiSharesResp.json"),
+	}
+}
+
+// BenchmarkDecodePerCollector decodes the responses once for every collector
+// that needs them, the way collectors used to before sharing a snapshot
+func BenchmarkDecodePerCollector(b *testing.B) {
+	responses := benchmarkResponses()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < jobsCollectors; j++ {
+			if _, err := api.ProcessJobsResponse(api.V0040, responses["jobs"]); err != nil {
+				b.Fatal(err)
+			}
+		}
+		for j := 0; j < nodesCollectors; j++ {
+			if _, err := api.ProcessNodesResponse(api.V0040, responses["nodes"]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkDecodeSharedSnapshot asks one snapshot for the same data
+func BenchmarkDecodeSharedSnapshot(b *testing.B) {
+	responses := benchmarkResponses()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := api.NewSnapshot(api.V0040, time.Now(), responses)
+		for j := 0; j < jobsCollectors; j++ {
+			if _, err := s.Jobs(); err != nil {
+				b.Fatal(err)
+			}
+		}
+		for j := 0; j < nodesCollectors; j++ {
+			if _, err := s.Nodes(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkCollectAll runs every collector against a new snapshot, as a scrape does
+func BenchmarkCollectAll(b *testing.B) {
+	responses := benchmarkResponses()
+	ch := make(chan prometheus.Metric)
+	go func() {
+		for range ch {
+		}
+	}()
+	defer close(ch)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := api.NewSnapshot(api.V0040, time.Now(), responses)
+		ctx := context.WithValue(context.Background(), types.ApiSnapshotKey, s)
+		collectors := []prometheus.Collector{
+			NewAccountsCollector(ctx),
+			NewCPUsCollector(ctx),
+			NewGPUsCollector(ctx),
+			NewNodesCollector(ctx),
+			NewNodeCollector(ctx),
+			NewPartitionsCollector(ctx),
+			NewFairShareCollector(ctx),
+			NewQueueCollector(ctx),
+			NewSchedulerCollector(ctx),
+			NewUsersCollector(ctx),
+		}
+		for _, c := range collectors {
+			c.Collect(ch)
+		}
+	}
+}
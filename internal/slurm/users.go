@@ -4,7 +4,6 @@ import (
 	"context"
 	"log/slog"
 
-	"github.com/akyoto/cache"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/api"
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
@@ -40,37 +39,35 @@ func (uc *UsersCollector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (uc *UsersCollector) Collect(ch chan<- prometheus.Metric) {
-	apiCache := uc.ctx.Value(types.ApiCacheKey).(*cache.Cache)
-	jobsRespBytes, found := apiCache.Get("jobs")
-	if !found {
-		slog.Error("failed to get jobs response for users metrics from cache")
-		return
-	}
-	jobsData, err := api.ProcessJobsResponse(jobsRespBytes.([]byte))
+	snapshot := uc.ctx.Value(types.ApiSnapshotKey).(*api.Snapshot)
+	jobsData, err := snapshot.Jobs()
 	if err != nil {
 		slog.Error("failed to process jobs data for users metrics", "error", err)
 		return
 	}
-	um, err := ParseUsersMetrics(jobsData)
+	cardinality, _ := uc.ctx.Value(types.CardinalityKey).(*Cardinality)
+	um, err := ParseUsersMetrics(jobsData, cardinality)
 	if err != nil {
 		slog.Error("failed to collect user metrics", "error", err)
 		return
 	}
+	pseudonyms, _ := uc.ctx.Value(types.LabelPseudonymsKey).(*LabelPseudonyms)
 	for u := range um {
+		user := pseudonyms.User(u)
 		if um[u].pending > 0 {
-			ch <- prometheus.MustNewConstMetric(uc.pending, prometheus.GaugeValue, um[u].pending, u)
+			ch <- prometheus.MustNewConstMetric(uc.pending, prometheus.GaugeValue, um[u].pending, user)
 		}
 		if um[u].pending_cpus > 0 {
-			ch <- prometheus.MustNewConstMetric(uc.pending_cpus, prometheus.GaugeValue, um[u].pending_cpus, u)
+			ch <- prometheus.MustNewConstMetric(uc.pending_cpus, prometheus.GaugeValue, um[u].pending_cpus, user)
 		}
 		if um[u].running > 0 {
-			ch <- prometheus.MustNewConstMetric(uc.running, prometheus.GaugeValue, um[u].running, u)
+			ch <- prometheus.MustNewConstMetric(uc.running, prometheus.GaugeValue, um[u].running, user)
 		}
 		if um[u].running_cpus > 0 {
-			ch <- prometheus.MustNewConstMetric(uc.running_cpus, prometheus.GaugeValue, um[u].running_cpus, u)
+			ch <- prometheus.MustNewConstMetric(uc.running_cpus, prometheus.GaugeValue, um[u].running_cpus, user)
 		}
 		if um[u].suspended > 0 {
-			ch <- prometheus.MustNewConstMetric(uc.suspended, prometheus.GaugeValue, um[u].suspended, u)
+			ch <- prometheus.MustNewConstMetric(uc.suspended, prometheus.GaugeValue, um[u].suspended, user)
 		}
 	}
 }
@@ -87,10 +84,28 @@ type userJobMetrics struct {
 	suspended    float64
 }
 
-func ParseUsersMetrics(jobsData *api.JobsData) (map[string]*userJobMetrics, error) {
+// cpus is what users are ranked by for the top N
+func (m *userJobMetrics) cpus() float64 {
+	return m.pending_cpus + m.running_cpus
+}
+
+func (m *userJobMetrics) add(o *userJobMetrics) {
+	m.pending += o.pending
+	m.pending_cpus += o.pending_cpus
+	m.running += o.running
+	m.running_cpus += o.running_cpus
+	m.suspended += o.suspended
+}
+
+// ParseUsersMetrics tallies the jobs of each user, limited by the cardinality
+func ParseUsersMetrics(jobsData *api.JobsData, c *Cardinality) (map[string]*userJobMetrics, error) {
+	filter, top := c.users()
 	users := make(map[string]*userJobMetrics)
 	for _, j := range jobsData.Jobs {
 		user := j.UserName
+		if !filter.Keep(user) {
+			continue
+		}
 		if _, exists := users[user]; !exists {
 			users[user] = NewUserJobMetrics()
 		}
@@ -106,5 +121,6 @@ func ParseUsersMetrics(jobsData *api.JobsData) (map[string]*userJobMetrics, erro
 			users[user].suspended++
 		}
 	}
+	topLabels(users, top, (*userJobMetrics).cpus, (*userJobMetrics).add)
 	return users, nil
 }
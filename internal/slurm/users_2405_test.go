@@ -1,5 +1,3 @@
-//go:build 2405
-
 package slurm
 
 import (
@@ -9,10 +7,13 @@ import (
 	"github.com/lcrownover/prometheus-slurm-exporter/internal/util"
 )
 
-func TestParseUsersMetrics(t *testing.T) {
+func TestParseUsersMetrics2405(t *testing.T) {
 	jobsBytes := util.ReadTestDataBytes("V0041OpenapiJobInfoResp.json")
-	jobsData, _ := api.ProcessJobsResponse(jobsBytes)
-	data, err := ParseUsersMetrics(jobsData)
+	jobsData, err := api.ProcessJobsResponse(api.V0041, jobsBytes)
+	if err != nil {
+		t.Fatalf("failed to decode jobs response: %v", err)
+	}
+	data, err := ParseUsersMetrics(jobsData, nil)
 	if err != nil {
 		t.Fatalf("failed to parse users metrics: %v", err)
 	}
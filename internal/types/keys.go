@@ -3,11 +3,22 @@ package types
 type Key int
 
 const (
-	ApiCacheKey Key = iota
-	ApiCacheTimeoutKey
-	ApiUserKey
+	ApiUserKey Key = iota
 	ApiTokenKey
 	ApiURLKey
+	ApiTargetKey
+	ApiVersionKey
+	ApiClientKey
+	ApiRetryOptionsKey
+	ApiBreakersKey
+	ApiIncrementalKey
+	ApiPollerKey
+	ApiResponseCacheKey
+	ApiSnapshotKey
+	ApiEnabledEndpointsKey
+	ScrapeTimeoutOffsetKey
+	LabelPseudonymsKey
+	CardinalityKey
 	ApiJobsEndpointKey
 	ApiNodesEndpointKey
 	ApiPartitionsEndpointKey
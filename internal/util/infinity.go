@@ -6,7 +6,8 @@ func CleanseInfinity(b []byte) []byte {
 	// this is disgusting but the response has values of "Infinity" which are
 	// not json unmarshal-able, so I manually replace all the "Infinity"s with the correct
 	// float64 value that represents Infinity.
-	// this will be fixed in v0.0.42
+	// v0.0.42 fixed this by wrapping those numbers in {set, infinite, number},
+	// so only the older versions need it.
 	// https://support.schedmd.com/show_bug.cgi?id=20817
 	//
 	// https://github.com/lcrownover/prometheus-slurm-exporter/issues/8
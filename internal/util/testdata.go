@@ -2,10 +2,10 @@ package util
 
 import (
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"path"
+	"path/filepath"
 	"runtime"
 )
 
@@ -34,17 +34,16 @@ func GetTestDataFilePath(filename string) string {
 // ReadTestDataBytes takes the short filename of the desired test data file
 // and returns that data as bytes.
 func ReadTestDataBytes(filename string) []byte {
-	filepath := GetTestDataFilePath(filename)
-	file, err := os.Open(filepath)
-	if err != nil {
-		log.Fatalf("failed to open file: %v\n", err)
-	}
-	defer file.Close()
-
-	data, err := io.ReadAll(file)
+	data, err := ReadFixtureBytes(getTestDataDir(), filename)
 	if err != nil {
 		log.Fatalf("failed to read file: %v\n", err)
 	}
-
 	return data
 }
+
+// ReadFixtureBytes returns the bytes of a recorded response in dir, which is
+// named like the files in the `testdata` directory. Unlike ReadTestDataBytes
+// it returns errors instead of exiting, so a missing file can be handled.
+func ReadFixtureBytes(dir string, filename string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(dir, filename))
+}